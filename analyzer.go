@@ -0,0 +1,208 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = []string{"info", "warning", "critical"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for i, name := range severityNames {
+		if string(text) == name {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown severity %q.", text)
+}
+
+type Finding struct {
+	ID          string   `json:"id"`
+	Severity    Severity `json:"severity"`
+	Message     string   `json:"message"`
+	Remediation string   `json:"remediation,omitempty"`
+}
+
+type Analyzer interface {
+	Analyze(c *Cert) []Finding
+}
+
+type AnalyzerFunc func(c *Cert) []Finding
+
+func (f AnalyzerFunc) Analyze(c *Cert) []Finding {
+	return f(c)
+}
+
+var analyzers = struct {
+	sync.RWMutex
+	names []string
+	m     map[string]Analyzer
+}{m: make(map[string]Analyzer)}
+
+func RegisterAnalyzer(name string, a Analyzer) {
+	analyzers.Lock()
+	defer analyzers.Unlock()
+
+	if _, ok := analyzers.m[name]; !ok {
+		analyzers.names = append(analyzers.names, name)
+	}
+	analyzers.m[name] = a
+}
+
+func UnregisterAnalyzer(name string) {
+	analyzers.Lock()
+	defer analyzers.Unlock()
+
+	if _, ok := analyzers.m[name]; !ok {
+		return
+	}
+	delete(analyzers.m, name)
+	for i, n := range analyzers.names {
+		if n == name {
+			analyzers.names = append(analyzers.names[:i:i], analyzers.names[i+1:]...)
+			break
+		}
+	}
+}
+
+func analyze(c *Cert) []Finding {
+	analyzers.RLock()
+	defer analyzers.RUnlock()
+
+	var findings []Finding
+	for _, name := range analyzers.names {
+		findings = append(findings, analyzers.m[name].Analyze(c)...)
+	}
+	return findings
+}
+
+var ExpiryWarning = 30 * 24 * time.Hour
+
+func analyzeExpiry(c *Cert) []Finding {
+	leaf := c.Detail()
+	if leaf == nil {
+		return nil
+	}
+
+	switch {
+	case now().After(leaf.NotAfter):
+		return []Finding{{ID: "expired", Severity: SeverityCritical, Message: fmt.Sprintf("Certificate expired at %s.", c.NotAfter), Remediation: "renew the certificate"}}
+	case now().Before(leaf.NotBefore):
+		return []Finding{{ID: "not-yet-valid", Severity: SeverityCritical, Message: fmt.Sprintf("Certificate is not valid before %s.", c.NotBefore), Remediation: "serve the previous certificate until this one is valid"}}
+	case now().Add(ExpiryWarning).After(leaf.NotAfter):
+		return []Finding{{ID: "expiring-soon", Severity: SeverityWarning, Message: fmt.Sprintf("Certificate expires at %s.", c.NotAfter), Remediation: "renew the certificate"}}
+	}
+	return nil
+}
+
+func isSelfSigned(c *x509.Certificate) bool {
+	return bytes.Equal(c.RawIssuer, c.RawSubject)
+}
+
+const weakKeyRemediation = "reissue with a 2048 bit or larger RSA key or a P-256 ECDSA key"
+
+func analyzeWeakKeys(c *Cert) []Finding {
+	var findings []Finding
+	for i, cert := range c.CertChain() {
+		if i > 0 && isSelfSigned(cert) {
+			continue
+		}
+
+		switch k := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if bits := k.N.BitLen(); bits < 2048 {
+				findings = append(findings, Finding{ID: "weak-key", Severity: SeverityCritical, Message: fmt.Sprintf("%s has a %d bit RSA key.", cert.Subject.CommonName, bits), Remediation: weakKeyRemediation})
+			}
+		case *ecdsa.PublicKey:
+			if bits := k.Curve.Params().BitSize; bits < 256 {
+				findings = append(findings, Finding{ID: "weak-key", Severity: SeverityCritical, Message: fmt.Sprintf("%s has a %d bit ECDSA key.", cert.Subject.CommonName, bits), Remediation: weakKeyRemediation})
+			}
+		case *dsa.PublicKey:
+			findings = append(findings, Finding{ID: "weak-key", Severity: SeverityCritical, Message: fmt.Sprintf("%s has a DSA key.", cert.Subject.CommonName), Remediation: weakKeyRemediation})
+		}
+
+		switch cert.SignatureAlgorithm {
+		case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+			findings = append(findings, Finding{ID: "weak-signature", Severity: SeverityWarning, Message: fmt.Sprintf("%s is signed with %s.", cert.Subject.CommonName, cert.SignatureAlgorithm), Remediation: "reissue with a SHA-256 signature"})
+		}
+	}
+	return findings
+}
+
+func analyzeChain(c *Cert) []Finding {
+	chain := c.CertChain()
+	if len(chain) == 0 {
+		return nil
+	}
+
+	if len(chain) == 1 && !isSelfSigned(chain[0]) {
+		return []Finding{{ID: "incomplete-chain", Severity: SeverityWarning, Message: fmt.Sprintf("No intermediate certificate for issuer %s was sent.", chain[0].Issuer.CommonName), Remediation: "include intermediate " + chain[0].Issuer.CommonName}}
+	}
+
+	var findings []Finding
+	for i := 0; i < len(chain)-1; i++ {
+		if !bytes.Equal(chain[i].RawIssuer, chain[i+1].RawSubject) {
+			findings = append(findings, Finding{ID: "chain-order", Severity: SeverityWarning, Message: fmt.Sprintf("Certificate %d (%s) is followed by %s instead of its issuer %s.", i, chain[i].Subject.CommonName, chain[i+1].Subject.CommonName, chain[i].Issuer.CommonName), Remediation: "serve the chain in order from the leaf to the root"})
+		}
+	}
+	return findings
+}
+
+func analyzePinnedCAs(c *Cert) []Finding {
+	if w := checkPinnedCAs(c.CertChain()); w != "" {
+		return []Finding{{ID: "pinned-ca", Severity: SeverityCritical, Message: w, Remediation: "reissue from a pinned CA"}}
+	}
+	return nil
+}
+
+func analyzeRevocationEndpoints(c *Cert) []Finding {
+	if !CheckRevocationEndpoints {
+		return nil
+	}
+
+	warnings, revokedBy := checkRevocationEndpoints(c.CertChain(), c.timeout)
+
+	var findings []Finding
+	if revokedBy != "" {
+		findings = append(findings, Finding{ID: "revoked", Severity: SeverityCritical, Message: fmt.Sprintf("Certificate is revoked according to %s.", revokedBy), Remediation: "replace the certificate"})
+	}
+	for _, w := range warnings {
+		findings = append(findings, Finding{ID: "revocation-endpoint", Severity: SeverityWarning, Message: w, Remediation: "report the endpoint to the issuing CA"})
+	}
+	return findings
+}
+
+func init() {
+	RegisterAnalyzer("expiry", AnalyzerFunc(analyzeExpiry))
+	RegisterAnalyzer("weak-key", AnalyzerFunc(analyzeWeakKeys))
+	RegisterAnalyzer("chain", AnalyzerFunc(analyzeChain))
+	RegisterAnalyzer("pinned-ca", AnalyzerFunc(analyzePinnedCAs))
+	RegisterAnalyzer("revocation-endpoints", AnalyzerFunc(analyzeRevocationEndpoints))
+	RegisterAnalyzer("profiles", AnalyzerFunc(analyzeProfiles))
+}
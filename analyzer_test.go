@@ -0,0 +1,155 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func countFindings(c *Cert, id string) int {
+	n := 0
+	for _, f := range c.Findings {
+		if f.ID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRegisterAnalyzer(t *testing.T) {
+	defer UnregisterAnalyzer("test")
+
+	RegisterAnalyzer("test", AnalyzerFunc(func(c *Cert) []Finding {
+		return []Finding{{ID: "test", Message: c.DomainName}}
+	}))
+
+	c := NewCert("example.com")
+	if n := len(c.Findings); n == 0 || c.Findings[n-1] != (Finding{ID: "test", Message: "example.com"}) {
+		t.Errorf(`unexpected Cert.Findings %v, want test finding last`, c.Findings)
+	}
+
+	UnregisterAnalyzer("test")
+
+	c = NewCert("example.com")
+	if countFindings(c, "test") != 0 {
+		t.Errorf(`unexpected Cert.Findings %v, want no test finding`, c.Findings)
+	}
+}
+
+func TestAnalyzeExpiry(t *testing.T) {
+	defer func() { now = time.Now }()
+
+	leaf := &x509.Certificate{
+		NotBefore: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+	}
+	c := &Cert{certChain: []*x509.Certificate{leaf}}
+
+	var tests = []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2016, time.December, 1, 0, 0, 0, 0, time.UTC), "not-yet-valid"},
+		{time.Date(2017, time.June, 1, 0, 0, 0, 0, time.UTC), ""},
+		{time.Date(2017, time.December, 15, 0, 0, 0, 0, time.UTC), "expiring-soon"},
+		{time.Date(2018, time.June, 1, 0, 0, 0, 0, time.UTC), "expired"},
+	}
+
+	for _, test := range tests {
+		now = func() time.Time { return test.now }
+
+		findings := analyzeExpiry(c)
+		got := ""
+		if len(findings) > 0 {
+			got = findings[0].ID
+		}
+		if got != test.want {
+			t.Errorf(`at %s: unexpected finding %q, want %q`, test.now, got, test.want)
+		}
+	}
+}
+
+func TestAnalyzeWeakKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Cert{certChain: []*x509.Certificate{{
+		Subject:            pkix.Name{CommonName: "example.com"},
+		PublicKey:          &key.PublicKey,
+		SignatureAlgorithm: x509.SHA1WithRSA,
+	}}}
+
+	findings := analyzeWeakKeys(c)
+	if len(findings) != 2 || findings[0].ID != "weak-key" || findings[1].ID != "weak-signature" {
+		t.Errorf(`unexpected findings %v, want weak-key and weak-signature`, findings)
+	}
+}
+
+func TestAnalyzeChain(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	other := newTestCA(t, "Other CA")
+	leaf := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+
+	var tests = []struct {
+		chain []*x509.Certificate
+		want  string
+	}{
+		{[]*x509.Certificate{leaf, ca.cert}, ""},
+		{[]*x509.Certificate{leaf}, "incomplete-chain"},
+		{[]*x509.Certificate{leaf, other.cert}, "chain-order"},
+		{[]*x509.Certificate{ca.cert}, ""},
+	}
+
+	for i, test := range tests {
+		findings := analyzeChain(&Cert{certChain: test.chain})
+		got := ""
+		if len(findings) > 0 {
+			got = findings[0].ID
+		}
+		if got != test.want {
+			t.Errorf(`#%d: unexpected finding %q, want %q`, i, got, test.want)
+		}
+	}
+}
+
+func TestIncompleteChainRemediation(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+
+	findings := analyzeChain(&Cert{certChain: []*x509.Certificate{leaf}})
+	if len(findings) != 1 || findings[0].Remediation != "include intermediate Test CA" {
+		t.Errorf(`unexpected findings %v, want remediation "include intermediate Test CA"`, findings)
+	}
+}
+
+func TestSeverityText(t *testing.T) {
+	for _, s := range []Severity{SeverityInfo, SeverityWarning, SeverityCritical} {
+		text, _ := s.MarshalText()
+
+		var got Severity
+		if err := got.UnmarshalText(text); err != nil || got != s {
+			t.Errorf(`round trip of %q: got %v, %v`, text, got, err)
+		}
+	}
+
+	var s Severity
+	if err := s.UnmarshalText([]byte("fatal")); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
@@ -2,27 +2,66 @@ package cert
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"io"
 	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 )
 
+// Deprecated: Use Scanner.SkipVerify.
 var SkipVerify = false
 
+// Deprecated: Use Scanner.UTC.
 var UTC = false
 
-var userTempl string
+var userTemplate *template.Template
 
+// Deprecated: Use Scanner.Timeout.
 var TimeoutSeconds = 3
 
+var ALPN []string
+
+var ClientCertificate *tls.Certificate
+
+func SetClientCertificate(certFile, keyFile string) error {
+	if certFile == "" {
+		return nil
+	}
+	if keyFile == "" {
+		keyFile = certFile
+	}
+
+	c, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	ClientCertificate = &c
+
+	return nil
+}
+
 func SetUserTempl(templ string) error {
 	if templ == "" {
 		return nil
@@ -38,20 +77,163 @@ func SetUserTempl(templ string) error {
 		if !os.IsNotExist(err) {
 			return err
 		}
-		userTempl = templ
+		content = []byte(templ)
+	}
+
+	t, err := template.New("user").Parse(string(content))
+	if err != nil {
+		return err
+	}
+	userTemplate = t
+
+	return nil
+}
+
+var fingerprintHashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+var FingerprintAlgorithms = []string{"sha256"}
+
+func RegisterFingerprintHash(name string, h func() hash.Hash) {
+	fingerprintHashes[strings.ToLower(name)] = h
+}
+
+func SetFingerprintAlgorithms(algs string) error {
+	if algs == "" {
 		return nil
 	}
 
-	userTempl = string(content)
+	names := strings.Split(algs, ",")
+	for i, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := fingerprintHashes[name]; !ok {
+			return fmt.Errorf("Unsupported fingerprint algorithm %q.", name)
+		}
+		names[i] = name
+	}
+
+	FingerprintAlgorithms = names
 
 	return nil
 }
 
+func fingerprints(der []byte) map[string]string {
+	fps := make(map[string]string, len(FingerprintAlgorithms))
+	for _, name := range FingerprintAlgorithms {
+		h := fingerprintHashes[name]()
+		h.Write(der)
+		sum := h.Sum(nil)
+
+		hex := make([]string, len(sum))
+		for i, b := range sum {
+			hex[i] = fmt.Sprintf("%02X", b)
+		}
+		fps[name] = strings.Join(hex, ":")
+	}
+	return fps
+}
+
+const spkiPinPrefix = "sha256/"
+
+var PinnedCAs []string
+
+func SetPinnedCAs(pins string) error {
+	if pins == "" {
+		return nil
+	}
+
+	// Subject DNs contain commas, so pins are separated by semicolons.
+	list := strings.Split(pins, ";")
+	for i, pin := range list {
+		pin = strings.TrimSpace(pin)
+		if strings.HasPrefix(pin, spkiPinPrefix) {
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, spkiPinPrefix))
+			if err != nil || len(sum) != sha256.Size {
+				return fmt.Errorf("Invalid SPKI pin %q.", pin)
+			}
+		}
+		list[i] = pin
+	}
+
+	PinnedCAs = list
+
+	return nil
+}
+
+func spkiHash(c *x509.Certificate) string {
+	sum := sha256.Sum256(c.RawSubjectPublicKeyInfo)
+	return spkiPinPrefix + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func matchesPin(c *x509.Certificate, pin string) bool {
+	if strings.HasPrefix(pin, spkiPinPrefix) {
+		return pin == spkiHash(c)
+	}
+	return pin == c.Subject.String()
+}
+
+var systemRoots = x509.SystemCertPool
+
+// pinnedChains returns the verified chains from the leaf to a system root
+// or to a sent certificate pinned by its SPKI hash.
+func pinnedChains(certChain []*x509.Certificate) [][]*x509.Certificate {
+	roots, err := systemRoots()
+	if err != nil || roots == nil {
+		roots = x509.NewCertPool()
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certChain[1:] {
+		intermediates.AddCert(c)
+		for _, pin := range PinnedCAs {
+			if strings.HasPrefix(pin, spkiPinPrefix) && matchesPin(c, pin) {
+				roots.AddCert(c)
+			}
+		}
+	}
+
+	// Expiry has its own finding, so verify as of the leaf's issuance.
+	chains, err := certChain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   certChain[0].NotBefore,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil
+	}
+	return chains
+}
+
+func checkPinnedCAs(certChain []*x509.Certificate) string {
+	if len(PinnedCAs) == 0 || len(certChain) == 0 {
+		return ""
+	}
+
+	for _, chain := range pinnedChains(certChain) {
+		for _, ca := range chain[1:] {
+			for _, pin := range PinnedCAs {
+				if matchesPin(ca, pin) {
+					return ""
+				}
+			}
+		}
+	}
+
+	return fmt.Sprintf("Certificate does not chain to pinned CA %s.", strings.Join(PinnedCAs, "; "))
+}
+
 const defaultPort = "443"
 
 func SplitHostPort(hostport string) (string, string, error) {
+	return splitHostPort(hostport, defaultPort)
+}
+
+func splitHostPort(hostport, defPort string) (string, string, error) {
 	if !strings.Contains(hostport, ":") {
-		return hostport, defaultPort, nil
+		return hostport, defPort, nil
 	}
 
 	host, port, err := net.SplitHostPort(hostport)
@@ -60,92 +242,356 @@ func SplitHostPort(hostport string) (string, string, error) {
 	}
 
 	if port == "" {
-		port = defaultPort
+		port = defPort
 	}
 
 	return host, port, nil
 }
 
+var SortSANs = false
+
+// keyType describes the public key by algorithm and size or curve, such as
+// "RSA 2048" or "ECDSA P-256".
+func keyType(c *x509.Certificate) string {
+	switch k := c.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return ""
+}
+
+func normalizeSANs(sans []string) []string {
+	seen := make(map[string]bool, len(sans))
+	normalized := make([]string, 0, len(sans))
+	for _, san := range sans {
+		san = strings.ToLower(strings.TrimSuffix(san, "."))
+		if seen[san] {
+			continue
+		}
+		seen[san] = true
+		normalized = append(normalized, san)
+	}
+
+	if SortSANs {
+		sort.Strings(normalized)
+	}
+	return normalized
+}
+
+var RetainChain = false
+
+// DERSink, if set, receives the raw chain of every successful check of
+// the package-level functions. It is called concurrently from NewCerts.
+//
+// Deprecated: Use Scanner.Sink.
+var DERSink func(c *Cert, der [][]byte)
+
+// maxInterned bounds the strings kept by intern. Issuers are few, so the
+// map only fills up in long-running processes seeing unusual names, and is
+// then started over.
+const maxInterned = 4096
+
+var interned = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+func intern(s string) string {
+	interned.Lock()
+	defer interned.Unlock()
+
+	if v, ok := interned.m[s]; ok {
+		return v
+	}
+	if len(interned.m) >= maxInterned {
+		interned.m = make(map[string]string)
+	}
+	interned.m[s] = s
+	return s
+}
+
 type Cert struct {
-	DomainName string   `json:"domainName"`
-	IP         string   `json:"ip"`
-	Issuer     string   `json:"issuer"`
-	CommonName string   `json:"commonName"`
-	SANs       []string `json:"sans"`
-	NotBefore  string   `json:"notBefore"`
-	NotAfter   string   `json:"notAfter"`
-	Error      string   `json:"error"`
-	SerialNumber string `json:"SerialNumber"`
-	SignatureAlgorithm string `json:"SignatureAlgorithm"`
-	PublicKeyAlgorithm string `json:"PublicKeyAlgorithm"`
-	PublicKey string `json:"PublicKey"`
-	PublicKeyStr string `json:"PublicKeyStr"`
-	certChain  []*x509.Certificate
-}
-
-var serverCert = func(host, port string) ([]*x509.Certificate, string, error) {
+	DomainName         string            `json:"domainName"`
+	IP                 string            `json:"ip"`
+	Host               string            `json:"host"`
+	Port               string            `json:"port"`
+	ServerName         string            `json:"serverName"`
+	Issuer             string            `json:"issuer"`
+	CommonName         string            `json:"commonName"`
+	SANs               []string          `json:"sans"`
+	NotBefore          string            `json:"notBefore"`
+	NotAfter           string            `json:"notAfter"`
+	Error              string            `json:"error"`
+	SerialNumber       string            `json:"SerialNumber"`
+	SignatureAlgorithm string            `json:"SignatureAlgorithm"`
+	PublicKeyAlgorithm string            `json:"PublicKeyAlgorithm"`
+	PublicKey          string            `json:"PublicKey"`
+	PublicKeyStr       string            `json:"PublicKeyStr"`
+	KeyType            string            `json:"keyType,omitempty"`
+	Fingerprints       map[string]string `json:"fingerprints"`
+	Findings           []Finding         `json:"findings,omitempty"`
+	Registrar          string            `json:"registrar,omitempty"`
+	RegistrantOrg      string            `json:"registrantOrg,omitempty"`
+	GeoIP              *GeoIP            `json:"geoip,omitempty"`
+	CNAMEs             []string          `json:"cnames,omitempty"`
+	Profiles           []ProfileResult   `json:"profiles,omitempty"`
+	certChain          []*x509.Certificate
+	// timeout bounds the lookups of analyzers and enrichments.
+	timeout time.Duration
+	// notBefore and notAfter keep the validity period NotBefore and
+	// NotAfter are formatted from.
+	notBefore time.Time
+	notAfter  time.Time
+}
+
+type target struct {
+	host         string
+	port         string
+	explicitPort bool
+	serverName   string
+	protocol     Protocol
+	resolver     *net.Resolver
+	scanner      *Scanner
+}
+
+var serverCert = func(t target) ([]*x509.Certificate, string, error) {
+	timeout := t.scanner.timeout()
+	if t.protocol.Timeout > 0 {
+		timeout = t.protocol.Timeout
+	}
+	deadline := time.Now().Add(timeout)
 	d := &net.Dialer{
-		Timeout: time.Duration(TimeoutSeconds) * time.Second,
+		Deadline: deadline,
+		Resolver: t.resolver,
 	}
-	conn, err := tls.DialWithDialer(d, "tcp", host+":"+port, &tls.Config{
-		InsecureSkipVerify: SkipVerify,
-	})
+	if d.Resolver == nil {
+		d.Resolver = resolver()
+	}
+	conn, err := d.Dial(Network, net.JoinHostPort(t.host, t.port))
 	if err != nil {
 		return []*x509.Certificate{&x509.Certificate{}}, "", err
 	}
 	defer conn.Close()
+	conn.SetDeadline(deadline)
+
+	ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	if t.protocol.StartTLS != nil {
+		if err := t.protocol.StartTLS(conn, t.serverName); err != nil {
+			return []*x509.Certificate{&x509.Certificate{}}, ip, err
+		}
+	}
+
+	config := &tls.Config{
+		ServerName:         t.serverName,
+		InsecureSkipVerify: t.scanner.skipVerify(),
+		NextProtos:         t.protocol.ALPN,
+	}
+	if len(ALPN) > 0 {
+		config.NextProtos = ALPN
+	}
+	if ClientCertificate != nil {
+		config.Certificates = []tls.Certificate{*ClientCertificate}
+	}
+
+	// Keep the certificates in case the server stalls later in the
+	// handshake. They are only seen once verification has passed.
+	var received []*x509.Certificate
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			if c, err := x509.ParseCertificate(raw); err == nil {
+				received = append(received, c)
+			}
+		}
+		return nil
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() && len(received) > 0 {
+			return received, ip, &partialHandshakeError{err}
+		}
+		return []*x509.Certificate{&x509.Certificate{}}, ip, err
+	}
 
-	addr := conn.RemoteAddr()
-	ip, _, _ := net.SplitHostPort(addr.String())
-	cert := conn.ConnectionState().PeerCertificates
+	cert := tlsConn.ConnectionState().PeerCertificates
 
 	return cert, ip, nil
 }
 
-func NewCert(hostport string) *Cert {
-	host, port, err := SplitHostPort(hostport)
-	if err != nil {
-		return &Cert{DomainName: host, Error: err.Error()}
+// partialHandshakeError is returned with the certificates received when
+// the handshake timed out after the server sent them.
+type partialHandshakeError struct {
+	err error
+}
+
+func (e *partialHandshakeError) Error() string {
+	return e.err.Error()
+}
+
+var RecheckResolver = ""
+
+func resolverAt(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+func isExpired(certChain []*x509.Certificate, err error) bool {
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &invalid) {
+		return invalid.Reason == x509.Expired
+	}
+	if err != nil || len(certChain) == 0 {
+		return false
+	}
+	return now().After(certChain[0].NotAfter)
+}
+
+func (sc *Scanner) checkTarget(t target) *Cert {
+	host := t.serverName
+	if !sc.beginCheck() {
+		return &Cert{DomainName: host, Error: errShutdown.Error()}
+	}
+	defer sc.endCheck()
+
+	t.scanner = sc
+	t = resolveService(t)
+	addr := net.JoinHostPort(t.host, t.port)
+
+	// No SNI is sent for IP addresses.
+	sni := t.serverName
+	if net.ParseIP(sni) != nil {
+		sni = ""
+	}
+	failed := func(ip, msg string) *Cert {
+		return &Cert{DomainName: host, IP: ip, Host: t.host, Port: t.port, ServerName: sni, Error: msg}
 	}
-	certChain, ip, err := serverCert(host, port)
+
+	if ExpandSRV && isSRVName(t.host) {
+		if err := sc.checkSRV(t.host); err != nil {
+			return failed("", err.Error())
+		}
+	}
+
+	if until, ok := unreachableUntil(addr); ok {
+		return failed("", fmt.Sprintf("Skipped unreachable host until %s.", until.Format(time.RFC3339)))
+	}
+	var findings []Finding
+
+	certChain, ip, err := fetch(t)
+	if p, ok := err.(*partialHandshakeError); ok {
+		findings = append(findings, Finding{ID: "timeout", Severity: SeverityWarning, Message: fmt.Sprintf("The handshake timed out after the certificate was received: %v", p.err), Remediation: "check the server's load and handshake latency"})
+		err = nil
+	}
+	if RecheckResolver != "" && isExpired(certChain, err) {
+		rt := t
+		rt.resolver = resolverAt(RecheckResolver)
+		chain, rip, rerr := fetch(rt)
+		if rerr == nil && len(chain) > 0 && !isExpired(chain, nil) {
+			findings = append(findings, Finding{ID: "stale-resolver", Severity: SeverityWarning, Message: fmt.Sprintf("An expired certificate was served, but %s resolved via %s serves a valid one.", rip, RecheckResolver), Remediation: "remove stale DNS records or caches"})
+			certChain, ip, err = chain, rip, nil
+		}
+	}
+	recordReachability(addr, err)
 	if err != nil {
-		return &Cert{DomainName: host, Error: err.Error()}
+		c := failed(ip, err.Error())
+		if f, ok := verificationFinding(err); ok {
+			c.Findings = []Finding{f}
+		}
+		return c
+	}
+	if len(certChain) == 0 {
+		return failed(ip, "No certificate was presented.")
 	}
 	cert := certChain[0]
 
-	var loc *time.Location
-	loc = time.Local
-	if UTC {
-		loc = time.UTC
-	}
-
-  pk := cert.PublicKey
-  var pk_info string
-  if str, ok := pk.(string); ok {
-    pk_info = str
-  } else {
-    pk_info = "not a string"
-  }
-
-	return &Cert{
-		DomainName: host,
-		IP:         ip,
-		Issuer:     cert.Issuer.CommonName,
-		CommonName: cert.Subject.CommonName,
-		SANs:       cert.DNSNames,
-		SerialNumber: cert.SerialNumber.String(),
+	loc := sc.location()
+
+	pk := cert.PublicKey
+	var pk_info string
+	if str, ok := pk.(string); ok {
+		pk_info = str
+	} else {
+		pk_info = "not a string"
+	}
+
+	c := &Cert{
+		DomainName:         host,
+		IP:                 ip,
+		Host:               t.host,
+		Port:               t.port,
+		ServerName:         sni,
+		Issuer:             intern(cert.Issuer.CommonName),
+		CommonName:         cert.Subject.CommonName,
+		SANs:               normalizeSANs(cert.DNSNames),
+		SerialNumber:       cert.SerialNumber.String(),
 		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
 		PublicKeyAlgorithm: cert.PublicKeyAlgorithm.String(),
-		PublicKey:  pk_info,
-		PublicKeyStr: fmt.Sprint(pk),
-		NotBefore:  cert.NotBefore.In(loc).String(),
-		NotAfter:   cert.NotAfter.In(loc).String(),
-		Error:      "",
-		certChain:  certChain,
+		PublicKey:          pk_info,
+		PublicKeyStr:       fmt.Sprint(pk),
+		KeyType:            keyType(cert),
+		Fingerprints:       fingerprints(cert.Raw),
+		Findings:           findings,
+		NotBefore:          cert.NotBefore.In(loc).String(),
+		NotAfter:           cert.NotAfter.In(loc).String(),
+		Error:              "",
+		certChain:          certChain,
+		timeout:            sc.timeout(),
+		notBefore:          cert.NotBefore,
+		notAfter:           cert.NotAfter,
+	}
+
+	if ResolveCNAME && net.ParseIP(t.host) == nil {
+		if c.CNAMEs, err = lookupCNAMEChain(t.host, c.timeout); err != nil {
+			c.Findings = append(c.Findings, Finding{ID: "cname-lookup", Severity: SeverityInfo, Message: err.Error()})
+		}
+	}
+
+	if RDAP {
+		enrichRDAP(c)
+	}
+
+	if GeoIPLookup != nil {
+		enrichGeoIP(c)
+	}
+
+	if len(VerifyProfiles) > 0 {
+		c.Profiles = verifyProfiles(c, certChain)
+	}
+
+	c.Findings = append(c.Findings, analyze(c)...)
+
+	if sink := sc.sink(); sink != nil {
+		der := make([][]byte, len(certChain))
+		for i, c := range certChain {
+			der[i] = c.Raw
+		}
+		if err := sink.Put(c, der); err != nil {
+			c.Findings = append(c.Findings, Finding{ID: "sink", Severity: SeverityInfo, Message: err.Error()})
+		}
+	}
+
+	if !RetainChain {
+		c.certChain = nil
 	}
+
+	return c
 }
 
 func (c *Cert) Detail() *x509.Certificate {
+	if len(c.certChain) == 0 {
+		return nil
+	}
 	return c.certChain[0]
 }
 
@@ -157,38 +603,227 @@ type Certs []*Cert
 
 var tokens = make(chan struct{}, 128)
 
+var SkipInvalid = false
+
+type InvalidTarget struct {
+	Index  int
+	Target string
+	Err    error
+}
+
+type ValidationError []InvalidTarget
+
+func (e ValidationError) Error() string {
+	lines := make([]string, len(e))
+	for i, t := range e {
+		lines[i] = fmt.Sprintf("  #%d %q: %v", t.Index, t.Target, t.Err)
+	}
+	return "Invalid domain names:\n" + strings.Join(lines, "\n")
+}
+
 func validate(s []string) error {
 	if len(s) < 1 {
 		return fmt.Errorf("Input at least one domain name.")
 	}
+	if err := checkSelection(Offset, Limit, SamplePercent); err != nil {
+		return err
+	}
+	if StreamBuffer < 0 {
+		return fmt.Errorf("Invalid stream buffer %d. Use 0 or more.", StreamBuffer)
+	}
+	if SkipInvalid {
+		return nil
+	}
+
+	var invalid ValidationError
+	for i, d := range s {
+		if _, err := parseTarget(d); err != nil {
+			invalid = append(invalid, InvalidTarget{Index: i, Target: d, Err: err})
+		}
+	}
+	if len(invalid) > 0 {
+		return invalid
+	}
+	return nil
+}
+
+var Offset = 0
+
+var Limit = 0
+
+var SamplePercent = 100.0
+
+var randPerm = sourcePerm
+
+func checkSelection(offset, limit int, percent float64) error {
+	if offset < 0 {
+		return fmt.Errorf("Invalid offset %d. Use 0 or more.", offset)
+	}
+	if limit < 0 {
+		return fmt.Errorf("Invalid limit %d. Use 0 for no limit or more.", limit)
+	}
+	if !(percent > 0 && percent <= 100) {
+		return fmt.Errorf("Invalid sample percentage %v. Use more than 0 and up to 100.", percent)
+	}
 	return nil
 }
 
-func NewCerts(s []string) (Certs, error) {
+func SetSelection(offset, limit int, percent float64) error {
+	if err := checkSelection(offset, limit, percent); err != nil {
+		return err
+	}
+	Offset, Limit, SamplePercent = offset, limit, percent
+	return nil
+}
+
+func selectTargets(s []string) []string {
+	if Offset > 0 {
+		if Offset >= len(s) {
+			return []string{}
+		}
+		s = s[Offset:]
+	}
+
+	if Limit > 0 && Limit < len(s) {
+		s = s[:Limit]
+	}
+
+	if SamplePercent >= 100 {
+		return s
+	}
+
+	n := int(math.Ceil(float64(len(s)) * SamplePercent / 100))
+	picked := randPerm(len(s))[:n]
+	sort.Ints(picked)
+
+	sampled := make([]string, n)
+	for i, p := range picked {
+		sampled[i] = s[p]
+	}
+	return sampled
+}
+
+var ExpandSRV = true
+
+var lookupSRV = resolverLookupSRV
+
+func isSRVName(name string) bool {
+	labels := strings.SplitN(name, ".", 3)
+	return len(labels) == 3 && strings.HasPrefix(labels[0], "_") && (labels[1] == "_tcp" || labels[1] == "_udp")
+}
+
+func (sc *Scanner) lookupSRV(service, proto, name string) (string, []*net.SRV, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sc.timeout())
+	defer cancel()
+	return lookupSRV(ctx, service, proto, name)
+}
+
+func (sc *Scanner) expandSRV(s []string) []string {
+	if !ExpandSRV {
+		return s
+	}
+
+	expanded := make([]string, 0, len(s))
+	for _, d := range s {
+		if !isSRVName(d) {
+			expanded = append(expanded, d)
+			continue
+		}
+
+		var hosts []string
+		_, addrs, err := sc.lookupSRV("", "", d)
+		for _, addr := range addrs {
+			// A target of "." means the service is decidedly not available.
+			if err == nil && addr.Target != "." {
+				hosts = append(hosts, net.JoinHostPort(strings.TrimSuffix(addr.Target, "."), strconv.Itoa(int(addr.Port))))
+			}
+		}
+		if len(hosts) == 0 {
+			// Keep the name so that its check reports why it has no hosts.
+			hosts = []string{d}
+		}
+		expanded = append(expanded, hosts...)
+	}
+	return expanded
+}
+
+// checkSRV returns why the SRV name has no host to check, if it has none.
+func (sc *Scanner) checkSRV(name string) error {
+	_, addrs, err := sc.lookupSRV("", "", name)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if addr.Target != "." {
+			return nil
+		}
+	}
+	return fmt.Errorf("The SRV record of %s says the service is not available.", name)
+}
+
+// prepareTargets selects targets before expanding SRV names, so that
+// offset, limit and sample count input entries and a small sample does
+// not look up the whole list.
+func (sc *Scanner) prepareTargets(s []string) []string {
+	return sc.expandSRV(selectTargets(s))
+}
+
+func (sc *Scanner) NewCerts(s []string) (Certs, error) {
+	if err := sc.checkStrict(); err != nil {
+		return nil, err
+	}
 	if err := validate(s); err != nil {
 		return nil, err
 	}
+	// The scan counts as in flight until its results are recorded, so
+	// that Shutdown does not return before the checkpoint is written.
+	if !sc.beginCheck() {
+		return nil, errShutdown
+	}
+	defer sc.endCheck()
+
+	s = sc.prepareTargets(s)
 
 	type indexer struct {
 		index int
 		cert  *Cert
 	}
 
-	ch := make(chan *indexer)
+	cp, err := openCheckpoint(Checkpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make(Certs, len(s))
+	var pending []int
 	for i, d := range s {
+		if c, ok := cp.lookup(d); ok {
+			certs[i] = c
+		} else {
+			pending = append(pending, i)
+		}
+	}
+
+	hostports := make([]string, len(pending))
+	for j, i := range pending {
+		hostports[j] = s[i]
+	}
+	l := newLimiter(sc, parseTargets(hostports))
+	ch := make(chan *indexer)
+	for _, j := range checkOrder(len(pending)) {
+		i := pending[j]
 		go func(i int, d string) {
-			tokens <- struct{}{}
-			ch <- &indexer{i, NewCert(d)}
-			<-tokens
-		}(i, d)
+			c := l.check(d)
+			cp.record(d, c)
+			ch <- &indexer{i, c}
+		}(i, s[i])
 	}
 
-	certs := make(Certs, len(s))
-	for range s {
+	for range pending {
 		i := <-ch
 		certs[i.index] = i.cert
 	}
-	return certs, nil
+	return certs, cp.finish(true)
 }
 
 const defaultTempl = `{{range .}}DomainName: {{.DomainName}}
@@ -203,54 +838,106 @@ SignatureAlgorithm: {{.SignatureAlgorithm}}
 PublicKeyAlgorithm: {{.PublicKeyAlgorithm}}
 PublicKey: {{.PublicKey}}
 PublicKeyStr: {{.PublicKeyStr}}
-Error:      {{.Error}}
+{{range $alg, $fp := .Fingerprints}}Fingerprint({{$alg}}): {{$fp}}
+{{end}}{{if .Registrar}}Registrar:  {{.Registrar}}
+{{end}}{{if .RegistrantOrg}}Registrant: {{.RegistrantOrg}}
+{{end}}{{if .CNAMEs}}CNAME:      {{.DomainName}}{{range .CNAMEs}} -> {{.}}{{end}}
+{{end}}{{with .GeoIP}}GeoIP:      {{.Country}} AS{{.ASN}} {{.ASOrg}}
+{{end}}{{range .Profiles}}Profile:    {{.Profile}} {{if .Valid}}valid{{else}}invalid: {{.Error}}{{end}}
+{{end}}{{range .Findings}}Finding:    [{{.Severity}}] {{.ID}}: {{.Message}}{{with .Remediation}} Remediation: {{.}}{{end}}
+{{end}}Error:      {{.Error}}
 
 {{end}}
 `
 
-func (certs Certs) String() string {
-	var b bytes.Buffer
+type cachedTemplate struct {
+	once  sync.Once
+	name  string
+	text  string
+	templ *template.Template
+	err   error
+}
 
-	templ := defaultTempl
-	if userTempl != "" {
-		templ = userTempl
+func (c *cachedTemplate) parse() (*template.Template, error) {
+	c.once.Do(func() {
+		c.templ, c.err = template.New(c.name).Parse(c.text)
+	})
+	return c.templ, c.err
+}
+
+var defaultTemplate = &cachedTemplate{name: "default", text: defaultTempl}
+
+func (certs Certs) WriteText(w io.Writer) error {
+	if userTemplate != nil {
+		return userTemplate.Execute(w, certs)
+	}
+
+	t, err := defaultTemplate.parse()
+	if err != nil {
+		return err
 	}
+	return t.Execute(w, certs)
+}
 
-	t := template.Must(template.New("default").Parse(templ))
-	if err := t.Execute(&b, certs); err != nil {
-		panic(err)
+func (certs Certs) String() string {
+	var b bytes.Buffer
+	if err := certs.WriteText(&b); err != nil {
+		fmt.Fprintf(&b, "\nError: %v\n", err)
 	}
 	return b.String()
 }
 
-const markdownTempl = `DomainName | IP | Issuer | NotBefore | NotAfter | CN | SANs | Error
---- | --- | --- | --- | --- | --- | --- | ---
-{{range .}}{{.DomainName}} | {{.IP}} | {{.Issuer}} | {{.NotBefore}} | {{.NotAfter}} | {{.CommonName}} | {{range .SANs}}{{.}}<br/>{{end}} | {{.Error}}
+const markdownTempl = `DomainName | IP | Issuer | NotBefore | NotAfter | CN | SANs | Findings | Error
+--- | --- | --- | --- | --- | --- | --- | --- | ---
+{{range .}}{{.DomainName}} | {{.IP}} | {{.Issuer}} | {{.NotBefore}} | {{.NotAfter}} | {{.CommonName}} | {{range .SANs}}{{.}}<br/>{{end}} | {{range .Findings}}{{.Severity}}: {{.Message}}<br/>{{end}} | {{.Error}}
 {{end}}
 `
 
 func (certs Certs) escapeStar() Certs {
-	for _, cert := range certs {
-		for i, san := range cert.SANs {
-			cert.SANs[i] = strings.Replace(san, "*", "\\*", -1)
+	escaped := make(Certs, len(certs))
+	for i, cert := range certs {
+		c := *cert
+		c.SANs = make([]string, len(cert.SANs))
+		for j, san := range cert.SANs {
+			c.SANs[j] = strings.Replace(san, "*", "\\*", -1)
 		}
+		escaped[i] = &c
+	}
+	return escaped
+}
+
+var markdownTemplate = &cachedTemplate{name: "markdown", text: markdownTempl}
+
+func (certs Certs) WriteMarkdown(w io.Writer) error {
+	t, err := markdownTemplate.parse()
+	if err != nil {
+		return err
 	}
-	return certs
+	return t.Execute(w, certs.escapeStar())
 }
 
 func (certs Certs) Markdown() string {
 	var b bytes.Buffer
-	t := template.Must(template.New("markdown").Parse(markdownTempl))
-	if err := t.Execute(&b, certs.escapeStar()); err != nil {
-		panic(err)
+	if err := certs.WriteMarkdown(&b); err != nil {
+		fmt.Fprintf(&b, "\nError: %v\n", err)
 	}
 	return b.String()
 }
 
-func (certs Certs) JSON() string {
+func (certs Certs) WriteJSON(w io.Writer) error {
 	data, err := json.Marshal(certs)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+func (certs Certs) JSON() string {
+	var b bytes.Buffer
+	if err := certs.WriteJSON(&b); err != nil {
+		data, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return string(data)
 	}
-	return string(data)
+	return b.String()
 }
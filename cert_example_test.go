@@ -16,6 +16,13 @@ func ExampleCerts_String() {
 	// NotAfter:   2018-01-01 00:00:00 +0000 UTC
 	// CommonName: example.com
 	// SANs:       [example.com www.example.com]
+	// SerialNumber: <nil>
+	// SignatureAlgorithm: 0
+	// PublicKeyAlgorithm: 0
+	// PublicKey: not a string
+	// PublicKeyStr: <nil>
+	// Fingerprint(sha256): E3:B0:C4:42:98:FC:1C:14:9A:FB:F4:C8:99:6F:B9:24:27:AE:41:E4:64:9B:93:4C:A4:95:99:1B:78:52:B8:55
+	// Finding:    [critical] expired: Certificate expired at 2018-01-01 00:00:00 +0000 UTC. Remediation: renew the certificate
 	// Error:
 }
 
@@ -24,9 +31,9 @@ func ExampleCerts_Markdown() {
 
 	fmt.Printf("%s", certs.Markdown())
 	// Output:
-	// DomainName | IP | Issuer | NotBefore | NotAfter | CN | SANs | Error
-	// --- | --- | --- | --- | --- | --- | --- | ---
-	// example.com | 127.0.0.1 | CA for test | 2017-01-01 00:00:00 +0000 UTC | 2018-01-01 00:00:00 +0000 UTC | example.com | example.com<br/>www.example.com<br/> |
+	// DomainName | IP | Issuer | NotBefore | NotAfter | CN | SANs | Findings | Error
+	// --- | --- | --- | --- | --- | --- | --- | --- | ---
+	// example.com | 127.0.0.1 | CA for test | 2017-01-01 00:00:00 +0000 UTC | 2018-01-01 00:00:00 +0000 UTC | example.com | example.com<br/>www.example.com<br/> | critical: Certificate expired at 2018-01-01 00:00:00 +0000 UTC.<br/> |
 }
 
 func ExampleCerts_JSON() {
@@ -34,5 +41,5 @@ func ExampleCerts_JSON() {
 
 	fmt.Printf("%s", certs.JSON())
 	// Output:
-	// [{"domainName":"example.com","ip":"127.0.0.1","issuer":"CA for test","commonName":"example.com","sans":["example.com","www.example.com"],"notBefore":"2017-01-01 00:00:00 +0000 UTC","notAfter":"2018-01-01 00:00:00 +0000 UTC","error":""}]
+	// [{"domainName":"example.com","ip":"127.0.0.1","host":"example.com","port":"443","serverName":"example.com","issuer":"CA for test","commonName":"example.com","sans":["example.com","www.example.com"],"notBefore":"2017-01-01 00:00:00 +0000 UTC","notAfter":"2018-01-01 00:00:00 +0000 UTC","error":"","SerialNumber":"\u003cnil\u003e","SignatureAlgorithm":"0","PublicKeyAlgorithm":"0","PublicKey":"not a string","PublicKeyStr":"\u003cnil\u003e","fingerprints":{"sha256":"E3:B0:C4:42:98:FC:1C:14:9A:FB:F4:C8:99:6F:B9:24:27:AE:41:E4:64:9B:93:4C:A4:95:99:1B:78:52:B8:55"},"findings":[{"id":"expired","severity":"critical","message":"Certificate expired at 2018-01-01 00:00:00 +0000 UTC.","remediation":"renew the certificate"}]}]
 }
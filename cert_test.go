@@ -1,25 +1,41 @@
 package cert
 
 import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"net"
 	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func stubCert() {
-	serverCert = func(host, port string) ([]*x509.Certificate, string, error) {
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
 		return []*x509.Certificate{
 			&x509.Certificate{
 				Issuer: pkix.Name{
 					CommonName: "CA for test",
 				},
 				Subject: pkix.Name{
-					CommonName: host,
+					CommonName: tg.host,
 				},
-				DNSNames:  []string{host, "www." + host},
+				DNSNames:  []string{tg.host, "www." + tg.host},
 				NotBefore: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC),
 				NotAfter:  time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
 			},
@@ -28,9 +44,9 @@ func stubCert() {
 					CommonName: "parent of CA for test",
 				},
 				Subject: pkix.Name{
-					CommonName: host,
+					CommonName: tg.host,
 				},
-				DNSNames:  []string{host, "*." + host},
+				DNSNames:  []string{tg.host, "*." + tg.host},
 				NotBefore: time.Date(2017, time.January, 1, 0, 0, 0, 0, time.UTC),
 				NotAfter:  time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
 			},
@@ -57,6 +73,80 @@ func TestValidateError(t *testing.T) {
 	}
 }
 
+func TestSetSelection(t *testing.T) {
+	defer func() { Offset, Limit, SamplePercent = 0, 0, 100 }()
+
+	var tests = []struct {
+		offset  int
+		limit   int
+		percent float64
+		valid   bool
+	}{
+		{0, 0, 100, true},
+		{10, 5, 0.5, true},
+		{-1, 0, 100, false},
+		{0, -1, 100, false},
+		{0, 0, 0, false},
+		{0, 0, -5, false},
+		{0, 0, 101, false},
+	}
+
+	for _, test := range tests {
+		err := SetSelection(test.offset, test.limit, test.percent)
+		if (err == nil) != test.valid {
+			t.Errorf(`SetSelection(%d, %d, %v): unexpected err %v, want valid %v`, test.offset, test.limit, test.percent, err, test.valid)
+		}
+	}
+
+	SamplePercent = 0
+	if _, err := NewCerts([]string{"example.com"}); err == nil {
+		t.Error(`unexpected nil, want error for a sample of 0%`)
+	}
+}
+
+func TestValidateInvalidEntries(t *testing.T) {
+	input := []string{"example.com", "example.com:99999", "exa mple.com", "gopher://example.com", "_ldap._tcp.example.com", "-example.com", "example.com:imaps", "example.com:nosuchservice"}
+
+	err := validate(input)
+
+	invalid, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf(`unexpected err %v, want ValidationError`, err)
+	}
+
+	var indices []int
+	for _, i := range invalid {
+		indices = append(indices, i.Index)
+		if i.Target != input[i.Index] {
+			t.Errorf(`unexpected Target %q at index %d, want %q`, i.Target, i.Index, input[i.Index])
+		}
+	}
+	if fmt.Sprint(indices) != "[1 2 3 5 7]" {
+		t.Errorf(`unexpected invalid indices %v, want [1 2 3 5 7]`, indices)
+	}
+	if !strings.Contains(err.Error(), `#3 "gopher://example.com": Unsupported scheme "gopher".`) {
+		t.Errorf(`unexpected err message %q`, err.Error())
+	}
+}
+
+func TestValidateSkipInvalid(t *testing.T) {
+	SkipInvalid = true
+	defer func() { SkipInvalid = false }()
+
+	if err := validate([]string{"example.com", "exa mple.com"}); err != nil {
+		t.Errorf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	certs, _ := NewCerts([]string{"example.com", "exa mple.com"})
+
+	if certs[0].Error != "" {
+		t.Errorf(`unexpected Cert.Error %q, want %q`, certs[0].Error, "")
+	}
+	if certs[1].Error == "" {
+		t.Error(`unexpected empty Cert.Error, want error`)
+	}
+}
+
 func TestSplitHostPort(t *testing.T) {
 	type want struct {
 		host string
@@ -96,7 +186,7 @@ func TestNewCert(t *testing.T) {
 	input := "example.com"
 
 	c := NewCert(input)
-	certChain, _, _ := serverCert(input, defaultPort)
+	certChain, _, _ := serverCert(target{host: input, port: defaultPort})
 	origCert := certChain[0]
 
 	if _, ok := interface{}(c).(*Cert); !ok {
@@ -115,7 +205,7 @@ func TestNewCert(t *testing.T) {
 		t.Errorf(`unexpected Cert.CommonName %q, want %q`, c.CommonName, "example.com")
 	}
 	if len(c.SANs) != 2 {
-		t.Errorf(`unexpected Cert.SANs length %q, want %q`, len(c.SANs), 2)
+		t.Errorf(`unexpected Cert.SANs length %d, want %d`, len(c.SANs), 2)
 	}
 	if c.SANs[0] != "example.com" {
 		t.Errorf(`unexpected Cert.SANs[0] %q, want %q`, c.SANs[0], "example.com")
@@ -134,6 +224,33 @@ func TestNewCert(t *testing.T) {
 	}
 }
 
+func TestNewCertDialedFields(t *testing.T) {
+	var tests = []struct {
+		hostport, host, port, serverName string
+	}{
+		{"example.com", "example.com", "443", "example.com"},
+		{"example.com:8443", "example.com", "8443", "example.com"},
+		{"192.0.2.1:8443", "192.0.2.1", "8443", ""},
+	}
+
+	for _, test := range tests {
+		c := NewCert(test.hostport)
+		if c.Host != test.host || c.Port != test.port || c.ServerName != test.serverName {
+			t.Errorf(`%s: unexpected dialed %q %q %q, want %q %q %q`, test.hostport, c.Host, c.Port, c.ServerName, test.host, test.port, test.serverName)
+		}
+	}
+
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		return nil, "192.0.2.1", errors.New("remote error: tls: handshake failure")
+	}
+	defer stubCert()
+
+	c := NewCert("example.com")
+	if c.IP != "192.0.2.1" || c.Host != "example.com" || c.Port != "443" || c.ServerName != "example.com" {
+		t.Errorf(`unexpected failed Cert %+v, want dialed fields set`, c)
+	}
+}
+
 func TestNewCerts(t *testing.T) {
 	input := []string{"example.com"}
 
@@ -144,8 +261,389 @@ func TestNewCerts(t *testing.T) {
 	}
 }
 
+const emptySHA256 = "E3:B0:C4:42:98:FC:1C:14:9A:FB:F4:C8:99:6F:B9:24:27:AE:41:E4:64:9B:93:4C:A4:95:99:1B:78:52:B8:55"
+
+func TestFingerprints(t *testing.T) {
+	defer func() { FingerprintAlgorithms = []string{"sha256"} }()
+
+	if err := SetFingerprintAlgorithms("SHA1, sha256"); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	c := NewCert("example.com")
+
+	if len(c.Fingerprints) != 2 {
+		t.Errorf(`unexpected Cert.Fingerprints length %d, want %d`, len(c.Fingerprints), 2)
+	}
+	if c.Fingerprints["sha1"] != "DA:39:A3:EE:5E:6B:4B:0D:32:55:BF:EF:95:60:18:90:AF:D8:07:09" {
+		t.Errorf(`unexpected sha1 fingerprint %q`, c.Fingerprints["sha1"])
+	}
+	if c.Fingerprints["sha256"] != emptySHA256 {
+		t.Errorf(`unexpected sha256 fingerprint %q, want %q`, c.Fingerprints["sha256"], emptySHA256)
+	}
+}
+
+func TestSetFingerprintAlgorithmsError(t *testing.T) {
+	if err := SetFingerprintAlgorithms("sha256,md5"); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+	if len(FingerprintAlgorithms) != 1 || FingerprintAlgorithms[0] != "sha256" {
+		t.Errorf(`unexpected FingerprintAlgorithms %v, want [sha256]`, FingerprintAlgorithms)
+	}
+}
+
+func TestRegisterFingerprintHash(t *testing.T) {
+	defer func() {
+		FingerprintAlgorithms = []string{"sha256"}
+		delete(fingerprintHashes, "sha224")
+	}()
+
+	RegisterFingerprintHash("SHA224", sha256.New224)
+	if err := SetFingerprintAlgorithms("sha224"); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	c := NewCert("example.com")
+
+	if _, ok := c.Fingerprints["sha224"]; !ok {
+		t.Errorf(`sha224 fingerprint was not computed`)
+	}
+}
+
+func TestPinnedCAs(t *testing.T) {
+	root := newTestCA(t, "Internal Root CA")
+	intermediate := root.issueCA(t, "Internal CA")
+	decoy := newTestCA(t, "Decoy CA")
+	leaf := intermediate.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		return []*x509.Certificate{leaf, intermediate.cert, decoy.cert}, "127.0.0.1", nil
+	}
+	defer func() {
+		PinnedCAs = nil
+		systemRoots = x509.SystemCertPool
+		stubCert()
+	}()
+
+	var tests = []struct {
+		pins    string
+		trusted bool
+		want    int
+	}{
+		{"CN=Internal Root CA", true, 0},
+		{"CN=Internal Root CA", false, 1},
+		{spkiHash(root.cert), true, 0},
+		{spkiHash(intermediate.cert), false, 0},
+		{"CN=Internal CA", true, 0},
+		{"Internal CA", true, 1},
+		{"CN=Decoy CA", true, 1},
+		{spkiHash(decoy.cert), true, 1},
+		{"CN=Other CA", true, 1},
+		{"CN=Other CA; CN=Internal Root CA", true, 0},
+	}
+
+	for _, test := range tests {
+		if err := SetPinnedCAs(test.pins); err != nil {
+			t.Fatalf(`unexpected err %s, want nil`, err.Error())
+		}
+		systemRoots = func() (*x509.CertPool, error) {
+			pool := x509.NewCertPool()
+			if test.trusted {
+				pool.AddCert(root.cert)
+			}
+			return pool, nil
+		}
+
+		c := NewCert("example.com")
+
+		if n := countFindings(c, "pinned-ca"); n != test.want {
+			t.Errorf(`pins %q, root trusted %v: unexpected Cert.Findings %v, want %d pinned-ca findings`, test.pins, test.trusted, c.Findings, test.want)
+		}
+	}
+}
+
+func TestSetPinnedCAsError(t *testing.T) {
+	defer func() { PinnedCAs = nil }()
+
+	for _, pins := range []string{"sha256/not base64", "sha256/AAAA"} {
+		if err := SetPinnedCAs(pins); err == nil {
+			t.Errorf(`SetPinnedCAs(%q): unexpected nil, want error`, pins)
+		}
+	}
+}
+
+func TestSelectTargets(t *testing.T) {
+	defer func() {
+		Offset = 0
+		Limit = 0
+		SamplePercent = 100
+		randPerm = sourcePerm
+	}()
+	randPerm = func(n int) []int {
+		p := make([]int, n)
+		for i := range p {
+			p[i] = n - 1 - i
+		}
+		return p
+	}
+
+	input := []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "e.example.com"}
+
+	var tests = []struct {
+		offset  int
+		limit   int
+		percent float64
+		want    []string
+	}{
+		{0, 0, 100, input},
+		{1, 0, 100, input[1:]},
+		{0, 2, 100, input[:2]},
+		{1, 2, 100, input[1:3]},
+		{5, 0, 100, []string{}},
+		{0, 10, 100, input},
+		{0, 0, 40, []string{"d.example.com", "e.example.com"}},
+		{0, 0, 50, []string{"c.example.com", "d.example.com", "e.example.com"}},
+		{1, 3, 50, []string{"c.example.com", "d.example.com"}},
+	}
+
+	for _, test := range tests {
+		Offset, Limit, SamplePercent = test.offset, test.limit, test.percent
+
+		got := selectTargets(input)
+
+		if strings.Join(got, ",") != strings.Join(test.want, ",") {
+			t.Errorf(`selectTargets() with offset %d, limit %d, sample %v%% = %v, want %v`, test.offset, test.limit, test.percent, got, test.want)
+		}
+	}
+}
+
+func TestExpandSRV(t *testing.T) {
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		switch name {
+		case "_ldap._tcp.example.com":
+			return name, []*net.SRV{
+				&net.SRV{Target: "ldap1.example.com.", Port: 636},
+				&net.SRV{Target: "ldap2.example.com.", Port: 636},
+			}, nil
+		case "_sips._tcp.example.com":
+			return name, []*net.SRV{&net.SRV{Target: ".", Port: 0}}, nil
+		}
+		return "", nil, &net.DNSError{Err: "no such host", Name: name}
+	}
+	defer func() { lookupSRV = resolverLookupSRV }()
+
+	input := []string{"example.com", "_ldap._tcp.example.com", "_sips._tcp.example.com", "_xmpp._tcp.example.org", "_dmarc.example.com"}
+	want := "example.com,ldap1.example.com:636,ldap2.example.com:636,_sips._tcp.example.com,_xmpp._tcp.example.org,_dmarc.example.com"
+
+	if got := strings.Join(defaultScanner.expandSRV(input), ","); got != want {
+		t.Errorf(`expandSRV(%v) = %v, want %v`, input, got, want)
+	}
+
+	ExpandSRV = false
+	defer func() { ExpandSRV = true }()
+
+	if got := strings.Join(defaultScanner.expandSRV(input), ","); got != strings.Join(input, ",") {
+		t.Errorf(`expandSRV(%v) = %v, want input unchanged`, input, got)
+	}
+}
+
+func TestNewCertsWithUnavailableSRV(t *testing.T) {
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if name == "_sips._tcp.example.com" {
+			return name, []*net.SRV{&net.SRV{Target: ".", Port: 0}}, nil
+		}
+		return "", nil, &net.DNSError{Err: "no such host", Name: name}
+	}
+	defer func() { lookupSRV = resolverLookupSRV }()
+
+	certs, err := NewCerts([]string{"example.com", "_sips._tcp.example.com", "_xmpp._tcp.example.org"})
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	if len(certs) != 3 {
+		t.Fatalf(`unexpected %d certs, want 3`, len(certs))
+	}
+	if want := "The SRV record of _sips._tcp.example.com says the service is not available."; certs[1].Error != want {
+		t.Errorf(`unexpected Cert.Error %q, want %q`, certs[1].Error, want)
+	}
+	if want := "lookup _xmpp._tcp.example.org: no such host"; certs[2].Error != want {
+		t.Errorf(`unexpected Cert.Error %q, want %q`, certs[2].Error, want)
+	}
+}
+
+func TestSelectTargetsBeforeSRVExpansion(t *testing.T) {
+	var looked []string
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		looked = append(looked, name)
+		return name, []*net.SRV{
+			&net.SRV{Target: "ldap1.example.com.", Port: 636},
+			&net.SRV{Target: "ldap2.example.com.", Port: 636},
+		}, nil
+	}
+	defer func() {
+		lookupSRV = resolverLookupSRV
+		Offset, Limit = 0, 0
+	}()
+
+	input := []string{"example.com", "_ldap._tcp.example.com", "example.org", "_ldap._tcp.example.org"}
+	Offset, Limit = 1, 2
+
+	want := "ldap1.example.com:636,ldap2.example.com:636,example.org"
+	if got := strings.Join(defaultScanner.prepareTargets(input), ","); got != want {
+		t.Errorf(`prepareTargets(%v) = %v, want %v`, input, got, want)
+	}
+	if len(looked) != 1 || looked[0] != "_ldap._tcp.example.com" {
+		t.Errorf(`unexpected SRV lookups %v, want only the selected name`, looked)
+	}
+}
+
+func TestNewCertsWithLimit(t *testing.T) {
+	defer func() { Limit = 0 }()
+	Limit = 1
+
+	certs, _ := NewCerts([]string{"example.com", "example.org"})
+
+	if len(certs) != 1 {
+		t.Errorf(`unexpected length %d, want %d`, len(certs), 1)
+	}
+}
+
+// stallingConn stops answering once the server has sent its first flight,
+// until stop is closed.
+type stallingConn struct {
+	net.Conn
+	wrote chan struct{}
+	stop  chan struct{}
+	once  sync.Once
+}
+
+func (c *stallingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.once.Do(func() { close(c.wrote) })
+	return n, err
+}
+
+func (c *stallingConn) Read(b []byte) (int, error) {
+	select {
+	case <-c.wrote:
+		<-c.stop
+		return 0, io.EOF
+	default:
+		return c.Conn.Read(b)
+	}
+}
+
+func TestPartialHandshake(t *testing.T) {
+	serverCertificate, _ := selfSigned(t, "slow.example.com")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tls.Server(&stallingConn{Conn: conn, wrote: make(chan struct{}), stop: stop}, &tls.Config{
+			Certificates: []tls.Certificate{serverCertificate},
+			MaxVersion:   tls.VersionTLS12,
+		}).Handshake()
+	}()
+
+	SkipVerify = true
+	defer func() { SkipVerify = false }()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	chain, ip, err := dialServerCert(target{host: "127.0.0.1", port: port, serverName: "slow.example.com", protocol: Protocol{Timeout: 200 * time.Millisecond}, scanner: defaultScanner})
+
+	if _, ok := err.(*partialHandshakeError); !ok {
+		t.Fatalf(`unexpected err %v, want a partial handshake`, err)
+	}
+	if len(chain) != 1 || chain[0].Subject.CommonName != "slow.example.com" || ip != "127.0.0.1" {
+		t.Errorf(`unexpected chain %v from %q, want the certificate of slow.example.com`, chain, ip)
+	}
+
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		return chain, ip, err
+	}
+	defer stubCert()
+
+	c := NewCert("slow.example.com")
+	if c.Error != "" || c.CommonName != "slow.example.com" || countFindings(c, "timeout") != 1 {
+		t.Errorf(`unexpected Cert %+v, want the certificate with a timeout finding`, c)
+	}
+}
+
+func TestRecheckExpired(t *testing.T) {
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		if tg.resolver == nil {
+			return []*x509.Certificate{&x509.Certificate{
+				Issuer:   pkix.Name{CommonName: "stale CA"},
+				NotAfter: time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC),
+			}}, "127.0.0.1", nil
+		}
+		return []*x509.Certificate{&x509.Certificate{
+			Issuer:   pkix.Name{CommonName: "CA for test"},
+			NotAfter: time.Date(2099, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}}, "192.0.2.1", nil
+	}
+	RecheckResolver = "192.0.2.53:53"
+	defer func() {
+		stubCert()
+		RecheckResolver = ""
+	}()
+
+	c := NewCert("example.com")
+
+	if c.IP != "192.0.2.1" {
+		t.Errorf(`unexpected Cert.IP %q, want %q`, c.IP, "192.0.2.1")
+	}
+	if c.Issuer != "CA for test" {
+		t.Errorf(`unexpected Cert.Issuer %q, want %q`, c.Issuer, "CA for test")
+	}
+	if countFindings(c, "stale-resolver") != 1 {
+		t.Errorf(`unexpected Cert.Findings %v, want 1 stale-resolver finding`, c.Findings)
+	}
+}
+
+func TestRecheckExpiredConfirmed(t *testing.T) {
+	rechecks := 0
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		if tg.resolver != nil {
+			rechecks++
+		}
+		return nil, "", x509.CertificateInvalidError{Reason: x509.Expired}
+	}
+	RecheckResolver = "192.0.2.53:53"
+	defer func() {
+		stubCert()
+		RecheckResolver = ""
+	}()
+
+	c := NewCert("example.com")
+
+	if rechecks != 1 {
+		t.Errorf(`unexpected rechecks %d, want %d`, rechecks, 1)
+	}
+	if c.Error == "" {
+		t.Error(`unexpected empty Cert.Error, want expired error`)
+	}
+}
+
 func TestCertsAsString(t *testing.T) {
-	certChain, _, _ := serverCert("example.com", defaultPort)
+	certChain, _, _ := serverCert(target{host: "example.com", port: defaultPort})
 	origCert := certChain[0]
 
 	expected := fmt.Sprintf(`DomainName: example.com
@@ -155,10 +653,17 @@ NotBefore:  %s
 NotAfter:   %s
 CommonName: example.com
 SANs:       [example.com www.example.com]
+SerialNumber: <nil>
+SignatureAlgorithm: 0
+PublicKeyAlgorithm: 0
+PublicKey: not a string
+PublicKeyStr: <nil>
+Fingerprint(sha256): %s
+Finding:    [critical] expired: Certificate expired at %s. Remediation: renew the certificate
 Error:      
 
 
-`, origCert.NotBefore.String(), origCert.NotAfter.String())
+`, origCert.NotBefore.String(), origCert.NotAfter.String(), emptySHA256, origCert.NotAfter.String())
 
 	certs, _ := NewCerts([]string{"example.com"})
 
@@ -168,14 +673,14 @@ Error:
 }
 
 func TestCertsAsMarkdown(t *testing.T) {
-	certChain, _, _ := serverCert("example.com", defaultPort)
+	certChain, _, _ := serverCert(target{host: "example.com", port: defaultPort})
 	origCert := certChain[0]
 
-	expected := fmt.Sprintf(`DomainName | IP | Issuer | NotBefore | NotAfter | CN | SANs | Error
---- | --- | --- | --- | --- | --- | --- | ---
-example.com | 127.0.0.1 | CA for test | %s | %s | example.com | example.com<br/>www.example.com<br/> | 
+	expected := fmt.Sprintf(`DomainName | IP | Issuer | NotBefore | NotAfter | CN | SANs | Findings | Error
+--- | --- | --- | --- | --- | --- | --- | --- | ---
+example.com | 127.0.0.1 | CA for test | %s | %s | example.com | example.com<br/>www.example.com<br/> | critical: Certificate expired at %s.<br/> | 
 
-`, origCert.NotBefore.String(), origCert.NotAfter.String())
+`, origCert.NotBefore.String(), origCert.NotAfter.String(), origCert.NotAfter.String())
 
 	certs, _ := NewCerts([]string{"example.com"})
 
@@ -185,10 +690,10 @@ example.com | 127.0.0.1 | CA for test | %s | %s | example.com | example.com<br/>
 }
 
 func TestCertsAsJSON(t *testing.T) {
-	certChain, _, _ := serverCert("example.com", defaultPort)
+	certChain, _, _ := serverCert(target{host: "example.com", port: defaultPort})
 	origCert := certChain[0]
 
-	expected := fmt.Sprintf("[{\"domainName\":\"example.com\",\"ip\":\"127.0.0.1\",\"issuer\":\"CA for test\",\"commonName\":\"example.com\",\"sans\":[\"example.com\",\"www.example.com\"],\"notBefore\":%q,\"notAfter\":%q,\"error\":\"\"}]", origCert.NotBefore.String(), origCert.NotAfter.String())
+	expected := fmt.Sprintf("[{\"domainName\":\"example.com\",\"ip\":\"127.0.0.1\",\"host\":\"example.com\",\"port\":\"443\",\"serverName\":\"example.com\",\"issuer\":\"CA for test\",\"commonName\":\"example.com\",\"sans\":[\"example.com\",\"www.example.com\"],\"notBefore\":%q,\"notAfter\":%q,\"error\":\"\",\"SerialNumber\":\"\\u003cnil\\u003e\",\"SignatureAlgorithm\":\"0\",\"PublicKeyAlgorithm\":\"0\",\"PublicKey\":\"not a string\",\"PublicKeyStr\":\"\\u003cnil\\u003e\",\"fingerprints\":{\"sha256\":%q},\"findings\":[{\"id\":\"expired\",\"severity\":\"critical\",\"message\":\"Certificate expired at %s.\",\"remediation\":\"renew the certificate\"}]}]", origCert.NotBefore.String(), origCert.NotAfter.String(), emptySHA256, origCert.NotAfter.String())
 
 	certs, _ := NewCerts([]string{"example.com"})
 
@@ -211,6 +716,36 @@ func TestCertsEscapeStarInSANs(t *testing.T) {
 	}
 }
 
+func TestCertsMarkdownDoesNotModifySANs(t *testing.T) {
+	certs := Certs{
+		&Cert{
+			SANs: []string{"*.example.com"},
+		},
+	}
+
+	certs.Markdown()
+	certs.Markdown()
+
+	if certs[0].SANs[0] != "*.example.com" {
+		t.Errorf(`unexpected SAN %q, want %q`, certs[0].SANs[0], "*.example.com")
+	}
+}
+
+func TestNormalizeSANs(t *testing.T) {
+	input := []string{"www.Example.com", "example.com", "WWW.example.com", "*.example.com.", "api.example.com"}
+
+	if got := normalizeSANs(input); fmt.Sprint(got) != "[www.example.com example.com *.example.com api.example.com]" {
+		t.Errorf(`normalizeSANs(%v) = %v`, input, got)
+	}
+
+	SortSANs = true
+	defer func() { SortSANs = false }()
+
+	if got := normalizeSANs(input); fmt.Sprint(got) != "[*.example.com api.example.com example.com www.example.com]" {
+		t.Errorf(`normalizeSANs(%v) with SortSANs = %v`, input, got)
+	}
+}
+
 func TestSetUserTempl(t *testing.T) {
 	_ = SetUserTempl("{{range .}}Issuer: {{.Issuer}}{{end}}")
 	expected := "Issuer: CA for test"
@@ -221,14 +756,61 @@ func TestSetUserTempl(t *testing.T) {
 		t.Errorf(`unexpected return value %q, want %q`, certs.String(), expected)
 	}
 
-	userTempl = ""
+	userTemplate = nil
+}
+
+func TestSetUserTemplError(t *testing.T) {
+	if err := SetUserTempl("{{range .}}{{.Issuer}"); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+	if userTemplate != nil {
+		t.Errorf(`unexpected userTemplate %q, want nil`, userTemplate.Root)
+	}
+}
+
+func TestCertsAsStringWithExecError(t *testing.T) {
+	_ = SetUserTempl("{{range .}}{{.Unknown}}{{end}}")
+	defer func() { userTemplate = nil }()
+
+	certs, _ := NewCerts([]string{"example.com"})
+
+	if err := certs.WriteText(&bytes.Buffer{}); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+	if got := certs.String(); !strings.Contains(got, "Error: ") {
+		t.Errorf(`unexpected return value %q, want error message`, got)
+	}
+}
+
+func TestDetailWithoutChain(t *testing.T) {
+	c := &Cert{DomainName: "example.com", Error: "dial tcp: i/o timeout"}
+
+	if detail := c.Detail(); detail != nil {
+		t.Errorf(`unexpected Cert.Detail() %v, want nil`, detail)
+	}
+}
+
+func TestNewCertWithEmptyChain(t *testing.T) {
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		return []*x509.Certificate{}, "127.0.0.1", nil
+	}
+	defer stubCert()
+
+	c := NewCert("example.com")
+
+	if c.Error == "" {
+		t.Error(`unexpected empty Cert.Error, want error`)
+	}
 }
 
 func TestDetail(t *testing.T) {
+	RetainChain = true
+	defer func() { RetainChain = false }()
+
 	input := "example.com"
 
 	c := NewCert(input)
-	certChain, _, _ := serverCert(input, defaultPort)
+	certChain, _, _ := serverCert(target{host: input, port: defaultPort})
 	origCert := certChain[0]
 	detail := c.Detail()
 
@@ -242,10 +824,13 @@ func TestDetail(t *testing.T) {
 }
 
 func TestCertChain(t *testing.T) {
+	RetainChain = true
+	defer func() { RetainChain = false }()
+
 	input := "example.com"
 
 	c := NewCert(input)
-	expectedChain, _, _ := serverCert(input, defaultPort)
+	expectedChain, _, _ := serverCert(target{host: input, port: defaultPort})
 	certChain := c.CertChain()
 
 	if _, ok := interface{}(certChain).([]*x509.Certificate); !ok {
@@ -253,7 +838,7 @@ func TestCertChain(t *testing.T) {
 	}
 
 	if len(expectedChain) != len(certChain) {
-		t.Errorf(`unexpected length %q, want %q`, len(certChain), len(expectedChain))
+		t.Errorf(`unexpected length %d, want %d`, len(certChain), len(expectedChain))
 	}
 
 	if certChain[0].Issuer.CommonName != "CA for test" {
@@ -265,7 +850,142 @@ func TestCertChain(t *testing.T) {
 	}
 }
 
+func TestCertChainNotRetained(t *testing.T) {
+	c := NewCert("example.com")
+
+	if c.Detail() != nil {
+		t.Errorf(`unexpected Cert.Detail() %v, want nil`, c.Detail())
+	}
+	if c.CertChain() != nil {
+		t.Errorf(`unexpected Cert.CertChain() %v, want nil`, c.CertChain())
+	}
+}
+
+func TestDERSink(t *testing.T) {
+	var got [][]byte
+	var gotCert *Cert
+	DERSink = func(c *Cert, der [][]byte) {
+		gotCert = c
+		got = der
+	}
+	defer func() { DERSink = nil }()
+
+	c := NewCert("example.com")
+
+	if gotCert != c {
+		t.Errorf(`DERSink was not called with the returned Cert`)
+	}
+	if len(got) != 2 {
+		t.Errorf(`unexpected DER chain length %d, want %d`, len(got), 2)
+	}
+}
+
+func TestInternIssuer(t *testing.T) {
+	certs, _ := NewCerts([]string{"example.com", "example.org"})
+
+	a, b := certs[0].Issuer, certs[1].Issuer
+	if a != b {
+		t.Fatalf(`unexpected issuers %q and %q`, a, b)
+	}
+	if unsafe.StringData(a) != unsafe.StringData(b) {
+		t.Errorf(`issuer %q was not interned`, a)
+	}
+}
+
+func benchmarkCerts() Certs {
+	s := make([]string, 100)
+	for i := range s {
+		s[i] = fmt.Sprintf("host%d.example.com", i)
+	}
+	certs, _ := NewCerts(s)
+	return certs
+}
+
+func BenchmarkCertsString(b *testing.B) {
+	certs := benchmarkCerts()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = certs.String()
+	}
+}
+
+func BenchmarkCertsStringWithUserTempl(b *testing.B) {
+	_ = SetUserTempl("{{range .}}{{.DomainName}}: {{.NotAfter}}\n{{end}}")
+	defer func() { userTemplate = nil }()
+
+	certs := benchmarkCerts()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = certs.String()
+	}
+}
+
+func BenchmarkCertsMarkdown(b *testing.B) {
+	certs := benchmarkCerts()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = certs.Markdown()
+	}
+}
+
+func BenchmarkCertsJSON(b *testing.B) {
+	certs := benchmarkCerts()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = certs.JSON()
+	}
+}
+
 func TestMain(m *testing.M) {
 	setup()
 	os.Exit(m.Run())
 }
+
+func TestKeyType(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		key      interface{}
+		expected string
+	}{
+		{&rsaKey.PublicKey, "RSA 1024"},
+		{&ecKey.PublicKey, "ECDSA P-384"},
+		{ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)), "Ed25519"},
+		{nil, ""},
+	}
+
+	for _, test := range tests {
+		if got := keyType(&x509.Certificate{PublicKey: test.key}); got != test.expected {
+			t.Errorf(`unexpected key type %q, want %q`, got, test.expected)
+		}
+	}
+}
+
+func TestCertJSONOmitsEmptyFindings(t *testing.T) {
+	if s := (Certs{&Cert{DomainName: "example.com"}}).JSON(); strings.Contains(s, "findings") {
+		t.Errorf(`unexpected findings in %s, want them omitted`, s)
+	}
+}
+
+func TestInternBounded(t *testing.T) {
+	for i := 0; i < maxInterned+10; i++ {
+		intern(fmt.Sprintf("CA %d", i))
+	}
+
+	interned.Lock()
+	n := len(interned.m)
+	interned.Unlock()
+	if n > maxInterned {
+		t.Errorf(`unexpected %d interned strings, want at most %d`, n, maxInterned)
+	}
+	if s := intern("CA for test"); s != "CA for test" {
+		t.Errorf(`unexpected interned string %q`, s)
+	}
+}
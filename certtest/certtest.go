@@ -0,0 +1,225 @@
+// Package certtest provides a fake cert.Fetcher for testing code that
+// acts on check results, such as alerting.
+package certtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/genkiroid/cert"
+)
+
+// Scenario is what the fake server of a host does on one check.
+type Scenario struct {
+	Chain []*x509.Certificate
+	IP    string
+	Err   error
+	Delay time.Duration
+}
+
+// FakeFetcher replays the scenarios scripted for each host in order and
+// repeats the last one once they are used up.
+type FakeFetcher struct {
+	// Roots, if set, verifies served chains against the server name like
+	// a client that does not skip verification.
+	Roots *x509.CertPool
+
+	mu        sync.Mutex
+	scenarios map[string][]Scenario
+	calls     map[string]int
+}
+
+func NewFakeFetcher() *FakeFetcher {
+	return &FakeFetcher{
+		scenarios: make(map[string][]Scenario),
+		calls:     make(map[string]int),
+	}
+}
+
+func (f *FakeFetcher) Script(host string, scenarios ...Scenario) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scenarios[host] = append(f.scenarios[host], scenarios...)
+}
+
+func (f *FakeFetcher) Calls(host string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[host]
+}
+
+func (f *FakeFetcher) Fetch(t cert.Target) ([]*x509.Certificate, string, error) {
+	f.mu.Lock()
+	scenarios := f.scenarios[t.Host]
+	n := f.calls[t.Host]
+	f.calls[t.Host]++
+	f.mu.Unlock()
+
+	if len(scenarios) == 0 {
+		return nil, "", fmt.Errorf("dial tcp: lookup %s: no such host", t.Host)
+	}
+	if n >= len(scenarios) {
+		n = len(scenarios) - 1
+	}
+	s := scenarios[n]
+
+	time.Sleep(s.Delay)
+	ip := s.IP
+	if ip == "" {
+		ip = "192.0.2.1"
+	}
+	if s.Err == nil && f.Roots != nil {
+		if err := verify(s.Chain, t.ServerName, f.Roots); err != nil {
+			return nil, ip, err
+		}
+	}
+	return s.Chain, ip, s.Err
+}
+
+// verify fails like a TLS handshake if chain does not verify.
+func verify(chain []*x509.Certificate, serverName string, roots *x509.CertPool) error {
+	if len(chain) == 0 {
+		return fmt.Errorf("tls: server sent no certificates")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+	if err != nil {
+		return &tls.CertificateVerificationError{UnverifiedCertificates: chain, Err: err}
+	}
+	return nil
+}
+
+// Roots returns a pool of the CAs that issued chains, to set as
+// FakeFetcher.Roots.
+func Roots(chains ...[]*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, chain := range chains {
+		pool.AddCert(chain[len(chain)-1])
+	}
+	return pool
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// Timeout is a scenario of a server that does not answer in time.
+func Timeout(after time.Duration) Scenario {
+	return Scenario{
+		Err:   &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}},
+		Delay: after,
+	}
+}
+
+// Serve is a scenario of a server presenting chain.
+func Serve(chain []*x509.Certificate) Scenario {
+	return Scenario{Chain: chain}
+}
+
+// Chain issues a leaf certificate for host, valid from notBefore to
+// notAfter, from a fresh CA and returns both.
+func Chain(host string, notBefore, notAfter time.Time) ([]*x509.Certificate, error) {
+	chain, _, err := issue(host, notBefore, notAfter, "")
+	return chain, err
+}
+
+// Revoked is a scenario of a server presenting a chain for host whose
+// leaf is listed on the CRL of its CA. The CRL is served until stop is
+// called. Set cert.CheckRevocationEndpoints for checks to look it up.
+func Revoked(host string) (s Scenario, stop func(), err error) {
+	var crl []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(crl)
+	}))
+
+	chain, caKey, err := issue(host, time.Now().Add(-time.Hour), time.Now().Add(90*24*time.Hour), ts.URL+"/ca.crl")
+	if err != nil {
+		ts.Close()
+		return Scenario{}, nil, err
+	}
+	crl, err = x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(24 * time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: chain[0].SerialNumber, RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, chain[1], caKey)
+	if err != nil {
+		ts.Close()
+		return Scenario{}, nil, err
+	}
+	return Serve(chain), ts.Close, nil
+}
+
+// issue issues a chain like Chain, with the leaf pointing to crlURL if
+// given, and returns the key of its CA.
+func issue(host string, notBefore, notAfter time.Time, crlURL string) ([]*x509.Certificate, *ecdsa.PrivateKey, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "certtest CA"},
+		NotBefore:             notBefore.Add(-time.Hour),
+		NotAfter:              notAfter.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	if crlURL != "" {
+		tmpl.CRLDistributionPoints = []string{crlURL}
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []*x509.Certificate{leaf, ca}, caKey, nil
+}
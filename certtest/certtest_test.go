@@ -0,0 +1,111 @@
+package certtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/genkiroid/cert"
+)
+
+func TestFakeFetcher(t *testing.T) {
+	valid, err := Chain("ok.example.com", time.Now().Add(-time.Hour), time.Now().Add(90*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := Chain("ok.example.com", time.Now().Add(-48*time.Hour), time.Now().Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFakeFetcher()
+	f.Script("ok.example.com", Serve(valid), Serve(expired))
+	f.Script("slow.example.com", Timeout(10*time.Millisecond))
+
+	cert.CertFetcher = f
+	defer func() { cert.CertFetcher = nil }()
+
+	first := cert.NewCert("ok.example.com")
+	if first.Error != "" || first.CommonName != "ok.example.com" || first.ExitCode() != cert.ExitOK {
+		t.Errorf(`unexpected first Cert %+v, want a valid certificate`, first)
+	}
+
+	for i := 0; i < 2; i++ {
+		c := cert.NewCert("ok.example.com")
+		if c.ExitCode() != cert.ExitCritical {
+			t.Errorf(`unexpected Cert %+v, want the expired certificate`, c)
+		}
+	}
+	if n := f.Calls("ok.example.com"); n != 3 {
+		t.Errorf(`unexpected calls %d, want 3`, n)
+	}
+
+	slow := cert.NewCert("slow.example.com")
+	if slow.Error != "dial tcp: i/o timeout" {
+		t.Errorf(`unexpected Cert.Error %q, want a timeout`, slow.Error)
+	}
+
+	unknown := cert.NewCert("unknown.example.com")
+	if unknown.Error == "" {
+		t.Error(`unexpected empty Cert.Error for a host without scenarios`)
+	}
+}
+
+func TestFakeFetcherRoots(t *testing.T) {
+	valid, err := Chain("ok.example.com", time.Now().Add(-time.Hour), time.Now().Add(90*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	untrusted, err := Chain("untrusted.example.com", time.Now().Add(-time.Hour), time.Now().Add(90*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := NewFakeFetcher()
+	f.Roots = Roots(valid)
+	f.Script("ok.example.com", Serve(valid))
+	f.Script("untrusted.example.com", Serve(untrusted))
+	f.Script("other.example.com", Serve(valid))
+
+	cert.CertFetcher = f
+	defer func() { cert.CertFetcher = nil }()
+
+	if c := cert.NewCert("ok.example.com"); c.Error != "" || c.ExitCode() != cert.ExitOK {
+		t.Errorf(`unexpected Cert %+v, want a verified certificate`, c)
+	}
+	for _, host := range []string{"untrusted.example.com", "other.example.com"} {
+		if c := cert.NewCert(host); c.Error == "" || c.ExitCode() != cert.ExitCritical {
+			t.Errorf(`unexpected Cert %+v for %s, want a failed verification`, c, host)
+		}
+	}
+}
+
+func TestRevoked(t *testing.T) {
+	revoked, stop, err := Revoked("revoked.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	f := NewFakeFetcher()
+	f.Roots = Roots(revoked.Chain)
+	f.Script("revoked.example.com", revoked)
+
+	cert.CertFetcher = f
+	cert.CheckRevocationEndpoints = true
+	defer func() {
+		cert.CertFetcher = nil
+		cert.CheckRevocationEndpoints = false
+	}()
+
+	c := cert.NewCert("revoked.example.com")
+	if c.Error != "" || c.ExitCode() != cert.ExitCritical {
+		t.Fatalf(`unexpected Cert %+v, want a revoked certificate`, c)
+	}
+	found := false
+	for _, finding := range c.Findings {
+		found = found || finding.ID == "revoked"
+	}
+	if !found {
+		t.Errorf(`unexpected findings %+v, want revoked`, c.Findings)
+	}
+}
@@ -0,0 +1,119 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Checkpoint, if set, is a file every finished check is appended to. A
+// scan started again with the same file reuses those results and only
+// checks the remaining targets. The file is removed once a scan
+// completes.
+var Checkpoint = ""
+
+type checkpointEntry struct {
+	Target string `json:"target"`
+	Cert   *Cert  `json:"cert"`
+}
+
+type checkpoint struct {
+	sync.Mutex
+	f          *os.File
+	enc        *json.Encoder
+	done       map[string]*Cert
+	incomplete bool
+	err        error
+}
+
+func openCheckpoint(path string) (*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	cp := &checkpoint{f: f, enc: json.NewEncoder(f), done: make(map[string]*Cert)}
+
+	// An interrupted write leaves a partial last line, which is dropped
+	// so that new entries start on a line of their own.
+	good := 0
+	for good < len(b) {
+		i := bytes.IndexByte(b[good:], '\n')
+		if i < 0 {
+			break
+		}
+		var e checkpointEntry
+		if err := json.Unmarshal(b[good:good+i], &e); err != nil || e.Cert == nil {
+			break
+		}
+		cp.done[e.Target] = e.Cert
+		good += i + 1
+	}
+	if err := f.Truncate(int64(good)); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(int64(good), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return cp, nil
+}
+
+func (cp *checkpoint) lookup(target string) (*Cert, bool) {
+	if cp == nil {
+		return nil, false
+	}
+	c, ok := cp.done[target]
+	return c, ok
+}
+
+func (cp *checkpoint) record(target string, c *Cert) {
+	if cp == nil {
+		return
+	}
+
+	cp.Lock()
+	defer cp.Unlock()
+
+	// Checks cut short by Shutdown or the budget are left for the next run.
+	if c.Error == errShutdown.Error() || c.Error == errBudgetExhausted.Error() {
+		cp.incomplete = true
+		return
+	}
+	if err := cp.enc.Encode(checkpointEntry{target, c}); err != nil && cp.err == nil {
+		cp.err = err
+	}
+}
+
+func (cp *checkpoint) finish(completed bool) error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.Lock()
+	defer cp.Unlock()
+
+	err := cp.f.Close()
+	if cp.err != nil {
+		return cp.err
+	}
+	if err != nil {
+		return err
+	}
+	if completed && !cp.incomplete {
+		return os.Remove(cp.f.Name())
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func tempCheckpoint(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(content)
+	f.Close()
+	return f.Name()
+}
+
+func TestCheckpointResume(t *testing.T) {
+	Checkpoint = tempCheckpoint(t, `{"target":"example.com","cert":{"domainName":"example.com","issuer":"From checkpoint"}}
+{"target":"example.org","cert":{"domainNa`)
+	defer func() {
+		os.Remove(Checkpoint)
+		Checkpoint = ""
+	}()
+
+	var mu sync.Mutex
+	var dialed []string
+	dial := serverCert
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		mu.Lock()
+		dialed = append(dialed, tg.host)
+		mu.Unlock()
+		return dial(tg)
+	}
+	defer stubCert()
+
+	certs, err := NewCerts([]string{"example.com", "example.org"})
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	if certs[0].Issuer != "From checkpoint" || certs[1].Issuer != "CA for test" {
+		t.Errorf(`unexpected issuers %q and %q`, certs[0].Issuer, certs[1].Issuer)
+	}
+	if len(dialed) != 1 || dialed[0] != "example.org" {
+		t.Errorf(`unexpected dialed hosts %v, want [example.org]`, dialed)
+	}
+	if _, err := os.Stat(Checkpoint); !os.IsNotExist(err) {
+		t.Errorf(`unexpected checkpoint left after a complete scan: %v`, err)
+	}
+}
+
+func TestCheckpointKeepsIncompleteScan(t *testing.T) {
+	Checkpoint = tempCheckpoint(t, "")
+	Budget = time.Second
+	defer func() {
+		os.Remove(Checkpoint)
+		Checkpoint = ""
+		Budget = 0
+	}()
+
+	if _, err := NewCerts([]string{"example.com"}); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	b, err := ioutil.ReadFile(Checkpoint)
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want checkpoint kept`, err.Error())
+	}
+	if len(b) != 0 {
+		t.Errorf(`unexpected checkpoint %q, want skipped checks left out`, b)
+	}
+}
+
+func TestStreamJSONCheckpointResume(t *testing.T) {
+	Checkpoint = tempCheckpoint(t, `{"target":"example.com","cert":{"domainName":"example.com","issuer":"From checkpoint"}}
+`)
+	defer func() {
+		os.Remove(Checkpoint)
+		Checkpoint = ""
+	}()
+
+	var b bytes.Buffer
+	if err := StreamJSON(&b, []string{"example.com", "example.org"}); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"issuer":"From checkpoint"`) {
+		t.Errorf(`unexpected output %q`, b.String())
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/genkiroid/cert"
 )
@@ -16,10 +21,40 @@ func main() {
 	var skipVerify bool
 	var utc bool
 	var timeout int
+	var digest string
+	var pin string
+	var offset int
+	var limit int
+	var sample float64
+	var recheckResolver string
+	var rdap bool
+	var geoip bool
+	var clientCert string
+	var alpn string
+	var skipInvalid bool
+	var sortSANs bool
+	var cname bool
+	var revocation bool
+	var exitCode bool
+	var budget time.Duration
+	var checkpoint string
+	var shuffle bool
+	var jitter time.Duration
+	var goResolver bool
+	var noProxy bool
+	var network string
+	var sni string
+	var profiles string
+	var profileRoots string
+	var layout string
+	var compare string
+	var compareHosts string
+	var metricsFile string
+	var webhook string
 	var showVersion bool
 
-	flag.StringVar(&format, "f", "simple table", "Output format. md: as markdown, json: as JSON. ")
-	flag.StringVar(&format, "format", "simple table", "Output format. md: as markdown, json: as JSON. ")
+	flag.StringVar(&format, "f", "simple table", "Output format. md: as markdown, json: as JSON, ndjson: as newline delimited JSON in completion order, openmetrics: as OpenMetrics text, sarif: as SARIF, html: as HTML table. ")
+	flag.StringVar(&format, "format", "simple table", "Output format. md: as markdown, json: as JSON, ndjson: as newline delimited JSON in completion order, openmetrics: as OpenMetrics text, sarif: as SARIF, html: as HTML table. ")
 	flag.StringVar(&template, "t", "", "Output format as Go template string or Go template file path.")
 	flag.StringVar(&template, "template", "", "Output format as Go template string or Go template file path.")
 	flag.BoolVar(&skipVerify, "k", false, "Skip verification of server's certificate chain and host name.")
@@ -28,6 +63,66 @@ func main() {
 	flag.BoolVar(&utc, "utc", false, "Use UTC to represent NotBefore and NotAfter.")
 	flag.IntVar(&timeout, "s", 3, "Timeout seconds.")
 	flag.IntVar(&timeout, "timeout", 3, "Timeout seconds.")
+	flag.StringVar(&digest, "d", "sha256", "Fingerprint digest algorithms, comma separated. sha1, sha256 and sha512 are supported.")
+	flag.StringVar(&digest, "digest", "sha256", "Fingerprint digest algorithms, comma separated. sha1, sha256 and sha512 are supported.")
+	flag.StringVar(&pin, "p", "", "Pinned CA the certificate must chain to, semicolon separated. sha256/<base64 SPKI hash> or subject DN such as CN=R3,O=Let's Encrypt,C=US.")
+	flag.StringVar(&pin, "pin", "", "Pinned CA the certificate must chain to, semicolon separated. sha256/<base64 SPKI hash> or subject DN such as CN=R3,O=Let's Encrypt,C=US.")
+	flag.IntVar(&offset, "o", 0, "Skip the first n domain names.")
+	flag.IntVar(&offset, "offset", 0, "Skip the first n domain names.")
+	flag.IntVar(&limit, "l", 0, "Check at most n domain names. 0 means no limit.")
+	flag.IntVar(&limit, "limit", 0, "Check at most n domain names. 0 means no limit.")
+	flag.Float64Var(&sample, "r", 100, "Check a random sample of the given percentage of domain names.")
+	flag.Float64Var(&sample, "sample", 100, "Check a random sample of the given percentage of domain names.")
+	flag.StringVar(&recheckResolver, "n", "", "DNS server (host:port) to re-check expired certificates through before reporting them.")
+	flag.StringVar(&recheckResolver, "recheck-resolver", "", "DNS server (host:port) to re-check expired certificates through before reporting them.")
+	flag.BoolVar(&rdap, "w", false, "Look up registrar and registrant organization of domain names via RDAP.")
+	flag.BoolVar(&rdap, "rdap", false, "Look up registrar and registrant organization of domain names via RDAP.")
+	flag.BoolVar(&geoip, "g", false, "Look up country and ASN of connected IPs via MaxMind. Set MAXMIND_ACCOUNT_ID and MAXMIND_LICENSE_KEY.")
+	flag.BoolVar(&geoip, "geoip", false, "Look up country and ASN of connected IPs via MaxMind. Set MAXMIND_ACCOUNT_ID and MAXMIND_LICENSE_KEY.")
+	flag.StringVar(&clientCert, "c", "", "Client certificate and key PEM files, comma separated. Give one file if it holds both.")
+	flag.StringVar(&clientCert, "client-cert", "", "Client certificate and key PEM files, comma separated. Give one file if it holds both.")
+	flag.StringVar(&alpn, "a", "", "ALPN protocols to offer, comma separated. Overrides the default of the target scheme.")
+	flag.StringVar(&alpn, "alpn", "", "ALPN protocols to offer, comma separated. Overrides the default of the target scheme.")
+	flag.BoolVar(&skipInvalid, "i", false, "Report malformed domain names as errors in the output instead of refusing to start.")
+	flag.BoolVar(&skipInvalid, "skip-invalid", false, "Report malformed domain names as errors in the output instead of refusing to start.")
+	flag.BoolVar(&sortSANs, "S", false, "Sort SANs alphabetically.")
+	flag.BoolVar(&sortSANs, "sort-sans", false, "Sort SANs alphabetically.")
+	flag.BoolVar(&cname, "C", false, "Resolve and show the CNAME chain followed for each host.")
+	flag.BoolVar(&cname, "cname", false, "Resolve and show the CNAME chain followed for each host.")
+	flag.BoolVar(&revocation, "e", false, "Check the OCSP, CRL and CA issuers endpoints referenced by each certificate.")
+	flag.BoolVar(&revocation, "check-endpoints", false, "Check the OCSP, CRL and CA issuers endpoints referenced by each certificate.")
+	flag.BoolVar(&exitCode, "x", false, "Exit with 1 on warnings, 2 on critical findings and 3 on failed checks.")
+	flag.BoolVar(&exitCode, "exit-code", false, "Exit with 1 on warnings, 2 on critical findings and 3 on failed checks.")
+	flag.DurationVar(&budget, "b", 0, "Spread the checks over this wall-clock time, e.g. 30m, and skip those that would not finish in it.")
+	flag.DurationVar(&budget, "budget", 0, "Spread the checks over this wall-clock time, e.g. 30m, and skip those that would not finish in it.")
+	flag.StringVar(&checkpoint, "R", "", "Record finished checks in this file and resume from it when run again. Removed once the scan completes.")
+	flag.StringVar(&checkpoint, "checkpoint", "", "Record finished checks in this file and resume from it when run again. Removed once the scan completes.")
+	flag.BoolVar(&shuffle, "m", false, "Check domain names in random order. Output keeps the given order.")
+	flag.BoolVar(&shuffle, "shuffle", false, "Check domain names in random order. Output keeps the given order.")
+	flag.DurationVar(&jitter, "j", 0, "Delay each check by a random time up to this, e.g. 2s.")
+	flag.DurationVar(&jitter, "jitter", 0, "Delay each check by a random time up to this, e.g. 2s.")
+	flag.BoolVar(&goResolver, "G", false, "Use Go's built-in DNS resolver instead of the system one.")
+	flag.BoolVar(&goResolver, "go-resolver", false, "Use Go's built-in DNS resolver instead of the system one.")
+	flag.BoolVar(&noProxy, "X", false, "Ignore proxy environment variables for RDAP, GeoIP and endpoint requests.")
+	flag.BoolVar(&noProxy, "no-proxy", false, "Ignore proxy environment variables for RDAP, GeoIP and endpoint requests.")
+	flag.StringVar(&network, "N", "tcp", "Network to connect over. tcp, tcp4 or tcp6.")
+	flag.StringVar(&network, "network", "tcp", "Network to connect over. tcp, tcp4 or tcp6.")
+	flag.StringVar(&sni, "I", "", "SNI names to check on the one given target, comma separated.")
+	flag.StringVar(&sni, "sni", "", "SNI names to check on the one given target, comma separated.")
+	flag.StringVar(&profiles, "V", "", "Client profiles to verify the certificate for, comma separated. modern, android-7.0 and java-8 are built in on top of the system roots.")
+	flag.StringVar(&profiles, "profiles", "", "Client profiles to verify the certificate for, comma separated. modern, android-7.0 and java-8 are built in on top of the system roots.")
+	flag.StringVar(&profileRoots, "P", "", "PEM root bundles to verify profiles with instead of the system roots, as name=file,name=file. Unknown names add profiles.")
+	flag.StringVar(&profileRoots, "profile-roots", "", "PEM root bundles to verify profiles with instead of the system roots, as name=file,name=file. Unknown names add profiles.")
+	flag.StringVar(&layout, "L", "", "Preset report layout. summary, full, expiry-only or security-audit. Combine with -f md or -f html.")
+	flag.StringVar(&layout, "layout", "", "Preset report layout. summary, full, expiry-only or security-audit. Combine with -f md or -f html.")
+	flag.StringVar(&compare, "D", "", "Compare two scans saved with -f json instead of checking, as label=file,label=file. Exits with 1 on differences.")
+	flag.StringVar(&compare, "compare", "", "Compare two scans saved with -f json instead of checking, as label=file,label=file. Exits with 1 on differences.")
+	flag.StringVar(&compareHosts, "H", "", "Domain names of the first scan to match to those of the second with -compare, as first=second,first=second.")
+	flag.StringVar(&compareHosts, "compare-hosts", "", "Domain names of the first scan to match to those of the second with -compare, as first=second,first=second.")
+	flag.StringVar(&metricsFile, "M", "", "Also write OpenMetrics text to this file, replaced atomically for the node_exporter textfile collector.")
+	flag.StringVar(&metricsFile, "metrics-file", "", "Also write OpenMetrics text to this file, replaced atomically for the node_exporter textfile collector.")
+	flag.StringVar(&webhook, "W", "", "Post warnings, critical findings and failed checks to this webhook URL as JSON.")
+	flag.StringVar(&webhook, "webhook", "", "Post warnings, critical findings and failed checks to this webhook URL as JSON.")
 	flag.BoolVar(&showVersion, "v", false, "Show version.")
 	flag.BoolVar(&showVersion, "version", false, "Show version.")
 	flag.Parse()
@@ -37,35 +132,220 @@ func main() {
 		return
 	}
 
+	if compare != "" {
+		if err := cert.SetCompareHosts(compareHosts); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(compareScans(compare))
+	}
+
 	var certs cert.Certs
 	var err error
 
 	cert.SkipVerify = skipVerify
 	cert.UTC = utc
 	cert.TimeoutSeconds = timeout
+	cert.RetainChain = template != ""
+	cert.RecheckResolver = recheckResolver
+	cert.RDAP = rdap
+	cert.SkipInvalid = skipInvalid
+	cert.SortSANs = sortSANs
+	cert.ResolveCNAME = cname
+	cert.CheckRevocationEndpoints = revocation
+	cert.Budget = budget
+	cert.Checkpoint = checkpoint
+	cert.Shuffle = shuffle
+	cert.Jitter = jitter
+	cert.PreferGo = goResolver
+	cert.NoProxy = noProxy
+
+	if geoip {
+		cert.GeoIPLookup = &cert.MaxMind{
+			AccountID:  os.Getenv("MAXMIND_ACCOUNT_ID"),
+			LicenseKey: os.Getenv("MAXMIND_LICENSE_KEY"),
+			Timeout:    time.Duration(timeout) * time.Second,
+		}
+	}
+
+	if alpn != "" {
+		cert.ALPN = strings.Split(alpn, ",")
+	}
+
+	certFile, keyFile := clientCert, ""
+	if i := strings.Index(clientCert, ","); i >= 0 {
+		certFile, keyFile = clientCert[:i], clientCert[i+1:]
+	}
+	if err := cert.SetClientCertificate(certFile, keyFile); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cert.SetProfileRoots(profileRoots); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := cert.SetVerifyProfiles(profiles); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
 
-	certs, err = cert.NewCerts(flag.Args())
+	if err := cert.SetSelection(offset, limit, sample); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cert.SetNetwork(network); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cert.SetFingerprintAlgorithms(digest); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cert.SetUserTempl(template); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if err := cert.SetPinnedCAs(pin); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	if format == "ndjson" && template == "" && sni == "" {
+		out := &exitCodeWriter{w: os.Stdout}
+		if err := cert.StreamJSON(out, flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if err := report(out.certs, metricsFile, webhook); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		if exitCode {
+			os.Exit(out.certs.ExitCode())
+		}
+		return
+	}
+
+	if sni != "" {
+		if flag.NArg() != 1 {
+			fmt.Fprintf(os.Stderr, "Input exactly one target with -sni.\n")
+			os.Exit(1)
+		}
+		certs, err = cert.NewCertsForSNIs(flag.Arg(0), strings.Split(sni, ","))
+	} else {
+		certs, err = cert.NewCerts(flag.Args())
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
-	if template == "" {
+	switch {
+	case template != "":
+		err = certs.WriteText(os.Stdout)
+	case layout != "" || format == "html":
+		if layout == "" {
+			layout = "full"
+		}
 		switch format {
-		case "md":
-			fmt.Printf("%s", certs.Markdown())
-		case "json":
-			fmt.Printf("%s", certs.JSON())
+		case "md", "html":
+			err = certs.Render(os.Stdout, layout, format)
 		default:
-			fmt.Printf("%s", certs)
+			err = certs.Render(os.Stdout, layout, "text")
 		}
-		return
+	case format == "md":
+		err = certs.WriteMarkdown(os.Stdout)
+	case format == "json":
+		err = certs.WriteJSON(os.Stdout)
+	case format == "openmetrics":
+		err = certs.WriteOpenMetrics(os.Stdout)
+	case format == "sarif":
+		err = certs.WriteSARIF(os.Stdout)
+	default:
+		err = certs.WriteText(os.Stdout)
 	}
-
-	if err := cert.SetUserTempl(template); err != nil {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := report(certs, metricsFile, webhook); err != nil {
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
+	if exitCode {
+		os.Exit(certs.ExitCode())
+	}
+}
 
-	fmt.Printf("%s", certs)
+// report writes the metrics file and posts to the webhook, if given.
+func report(certs cert.Certs, metricsFile, webhook string) error {
+	if metricsFile != "" {
+		if err := certs.WriteOpenMetricsFile(metricsFile); err != nil {
+			return err
+		}
+	}
+	if webhook != "" {
+		return certs.Notify(webhook, cert.SeverityWarning)
+	}
+	return nil
+}
+
+func compareScans(arg string) int {
+	scans := strings.Split(arg, ",")
+	if len(scans) != 2 {
+		fmt.Fprintf(os.Stderr, "Input exactly two scans with -compare.\n")
+		return 1
+	}
+
+	var sets [2]cert.LabeledCerts
+	for i, scan := range scans {
+		label, path := scan, scan
+		if j := strings.Index(scan, "="); j >= 0 {
+			label, path = scan[:j], scan[j+1:]
+		}
+		var err error
+		if sets[i], err = cert.ReadLabeledCerts(label, path); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	diffs := cert.Compare(sets[0], sets[1])
+	for _, d := range diffs {
+		fmt.Println(d)
+	}
+	if len(diffs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// exitCodeWriter passes streamed results through and keeps what the exit
+// code is computed from once the stream ends.
+type exitCodeWriter struct {
+	w     io.Writer
+	buf   []byte
+	certs cert.Certs
+}
+
+func (e *exitCodeWriter) Write(p []byte) (int, error) {
+	n, err := e.w.Write(p)
+	e.buf = append(e.buf, p[:n]...)
+	for {
+		i := bytes.IndexByte(e.buf, '\n')
+		if i < 0 {
+			break
+		}
+		var c cert.Cert
+		if json.Unmarshal(e.buf[:i], &c) == nil {
+			e.certs = append(e.certs, &cert.Cert{Error: c.Error, Findings: c.Findings})
+		}
+		e.buf = e.buf[i+1:]
+	}
+	return n, err
 }
@@ -0,0 +1,47 @@
+package cert
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+var ResolveCNAME = false
+
+// Nameserver is the host:port of the nameserver asked for CNAMEs instead
+// of the system resolver.
+var Nameserver = ""
+
+// maxCNAMEs bounds the chain followed, as CNAME loops are not resolved.
+const maxCNAMEs = 8
+
+// lookupCNAMEChain follows the CNAMEs of host one name at a time. A
+// resolver that only reports the canonical name yields a chain of one.
+func lookupCNAMEChain(host string, timeout time.Duration) ([]string, error) {
+	r := resolver()
+	if Nameserver != "" {
+		r = resolverAt(Nameserver)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var chain []string
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	for len(chain) < maxCNAMEs {
+		next, err := r.LookupCNAME(ctx, name)
+		if err != nil {
+			if len(chain) > 0 {
+				break
+			}
+			return nil, err
+		}
+		next = strings.ToLower(strings.TrimSuffix(next, "."))
+		if next == name {
+			break
+		}
+		chain = append(chain, next)
+		name = next
+	}
+	return chain, nil
+}
@@ -0,0 +1,134 @@
+package cert
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dnsRR(name []byte, rtype uint16, rdata []byte) []byte {
+	rr := append([]byte{}, name...)
+	rr = append(rr, byte(rtype>>8), byte(rtype), 0, 1, 0, 0, 0, 60, byte(len(rdata)>>8), byte(len(rdata)))
+	return append(rr, rdata...)
+}
+
+func dnsLabels(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+var fakeCNAMEs = map[string]string{
+	"www.example.com": "cdn.example.net",
+	"cdn.example.net": "Edge.Provider.net",
+}
+
+// fakeDNSResponse answers like a recursive resolver: CNAME questions with
+// the CNAME of the name only and A questions with the whole chain and the
+// address it ends at.
+func fakeDNSResponse(query []byte) []byte {
+	var labels []string
+	off := 12
+	for off < len(query) && query[off] != 0 {
+		labels = append(labels, string(query[off+1:off+1+int(query[off])]))
+		off += 1 + int(query[off])
+	}
+	name := strings.ToLower(strings.Join(labels, "."))
+	qtype := binary.BigEndian.Uint16(query[off+1:])
+
+	// The answers replace any additional records of the query.
+	resp := append([]byte{}, query[:off+5]...)
+	resp[2] |= 0x80
+	resp[3] |= 0x80
+	binary.BigEndian.PutUint16(resp[10:], 0)
+
+	if _, ok := fakeCNAMEs[name]; !ok && name != "edge.provider.net" {
+		resp[3] |= 3
+		return resp
+	}
+
+	n := 0
+	owner := []byte{0xC0, 12}
+	for next := fakeCNAMEs[name]; next != ""; next = fakeCNAMEs[strings.ToLower(next)] {
+		resp = append(resp, dnsRR(owner, 5, dnsLabels(next))...)
+		n++
+		if qtype == 5 {
+			break
+		}
+		owner = dnsLabels(strings.ToLower(next))
+	}
+	if qtype == 1 {
+		resp = append(resp, dnsRR(owner, 1, []byte{192, 0, 2, 1})...)
+		n++
+	}
+	binary.BigEndian.PutUint16(resp[6:], uint16(n))
+	return resp
+}
+
+func fakeDNSServer(t *testing.T) (string, func()) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on udp: %v", err)
+	}
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(fakeDNSResponse(buf[:n]), addr)
+		}
+	}()
+
+	return pc.LocalAddr().String(), func() { pc.Close() }
+}
+
+func TestLookupCNAMEChain(t *testing.T) {
+	server, closeServer := fakeDNSServer(t)
+	defer closeServer()
+
+	Nameserver = server
+	defer func() { Nameserver = "" }()
+
+	chain, err := lookupCNAMEChain("www.example.com", time.Second)
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	// The pure Go resolver reports the first CNAME of whichever of its
+	// parallel queries is answered first, so one hop may be skipped.
+	if got := strings.Join(chain, ","); got != "cdn.example.net,edge.provider.net" && got != "edge.provider.net" {
+		t.Errorf(`unexpected chain %v`, chain)
+	}
+
+	if _, err := lookupCNAMEChain("missing.example.com", time.Second); err == nil {
+		t.Error(`unexpected nil, want an error for a missing host`)
+	}
+}
+
+func TestNewCertWithCNAMEs(t *testing.T) {
+	server, closeServer := fakeDNSServer(t)
+	defer closeServer()
+
+	Nameserver = server
+	ResolveCNAME = true
+	defer func() {
+		Nameserver = ""
+		ResolveCNAME = false
+	}()
+
+	c := NewCert("www.example.com")
+
+	if len(c.CNAMEs) == 0 || c.CNAMEs[len(c.CNAMEs)-1] != "edge.provider.net" {
+		t.Fatalf(`unexpected Cert.CNAMEs %v`, c.CNAMEs)
+	}
+	if want := "CNAME:      www.example.com -> " + strings.Join(c.CNAMEs, " -> ") + "\n"; !strings.Contains(Certs{c}.String(), want) {
+		t.Errorf(`%q was not rendered in %q`, want, Certs{c}.String())
+	}
+}
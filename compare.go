@@ -0,0 +1,185 @@
+package cert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpiryDriftWindow is how far apart the expiry of two certificates of a
+// host may be before Compare reports it.
+var ExpiryDriftWindow = 7 * 24 * time.Hour
+
+// CompareHosts maps domain names of the first scan given to Compare to
+// those of the second, for environments that name their hosts differently.
+var CompareHosts map[string]string
+
+// SetCompareHosts sets CompareHosts from a comma separated list of
+// first=second domain name pairs.
+func SetCompareHosts(s string) error {
+	if s == "" {
+		CompareHosts = nil
+		return nil
+	}
+
+	hosts := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i <= 0 || i == len(pair)-1 {
+			return fmt.Errorf("Invalid host mapping %q. Use first=second.", pair)
+		}
+		hosts[pair[:i]] = pair[i+1:]
+	}
+	CompareHosts = hosts
+	return nil
+}
+
+type LabeledCerts struct {
+	Label string
+	Certs Certs
+}
+
+// ReadLabeledCerts reads a scan saved with -f json.
+func ReadLabeledCerts(label, path string) (LabeledCerts, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return LabeledCerts{}, err
+	}
+
+	var certs Certs
+	if err := json.Unmarshal(b, &certs); err != nil {
+		return LabeledCerts{}, fmt.Errorf("%s is not a JSON scan result: %v.", path, err)
+	}
+	return LabeledCerts{Label: label, Certs: certs}, nil
+}
+
+type Difference struct {
+	DomainName string            `json:"domainName"`
+	Port       string            `json:"port,omitempty"`
+	Field      string            `json:"field"`
+	Values     map[string]string `json:"values"`
+}
+
+func (d Difference) String() string {
+	labels := make([]string, 0, len(d.Values))
+	for l := range d.Values {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	host := d.DomainName
+	if d.Port != "" {
+		host = net.JoinHostPort(d.DomainName, d.Port)
+	}
+	s := fmt.Sprintf("%s: %s differs:", host, d.Field)
+	for _, l := range labels {
+		s += fmt.Sprintf(" %s %q", l, d.Values[l])
+	}
+	return s
+}
+
+type compareKey struct {
+	domainName string
+	port       string
+}
+
+func byHost(certs Certs, hosts map[string]string) map[compareKey][]*Cert {
+	m := make(map[compareKey][]*Cert, len(certs))
+	for _, c := range certs {
+		k := compareKey{c.DomainName, c.Port}
+		if h, ok := hosts[c.DomainName]; ok {
+			k.domainName = h
+		}
+		m[k] = append(m[k], c)
+	}
+	return m
+}
+
+// comparedKeyType falls back to the algorithm for scans saved before key
+// sizes and curves were recorded.
+func (c *Cert) comparedKeyType() string {
+	if c.KeyType != "" {
+		return c.KeyType
+	}
+	return c.PublicKeyAlgorithm
+}
+
+// Compare reports the hosts where a and b, e.g. staging and production,
+// present different issuers, key types or expiry, or where only one of
+// them could be checked. Hosts are matched by domain name, mapped through
+// CompareHosts, and port, and reported under the domain name of b. Hosts
+// listed more than once in either are reported instead of compared. Equal
+// labels are told apart by a " (1)" and " (2)" suffix.
+func Compare(a, b LabeledCerts) []Difference {
+	am, bm := byHost(a.Certs, CompareHosts), byHost(b.Certs, nil)
+
+	aLabel, bLabel := a.Label, b.Label
+	if aLabel == bLabel {
+		aLabel, bLabel = aLabel+" (1)", bLabel+" (2)"
+	}
+
+	var keys []compareKey
+	for k := range am {
+		keys = append(keys, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].domainName != keys[j].domainName {
+			return keys[i].domainName < keys[j].domainName
+		}
+		return keys[i].port < keys[j].port
+	})
+
+	var diffs []Difference
+	for _, k := range keys {
+		add := func(field, av, bv string) {
+			diffs = append(diffs, Difference{DomainName: k.domainName, Port: k.port, Field: field, Values: map[string]string{aLabel: av, bLabel: bv}})
+		}
+
+		as, bs := am[k], bm[k]
+		switch {
+		case len(as) > 1 || len(bs) > 1:
+			add("count", strconv.Itoa(len(as)), strconv.Itoa(len(bs)))
+			continue
+		case len(as) == 0:
+			add("presence", "missing", "present")
+			continue
+		case len(bs) == 0:
+			add("presence", "present", "missing")
+			continue
+		}
+
+		ac, bc := as[0], bs[0]
+		if ac.Error != "" || bc.Error != "" {
+			if (ac.Error == "") != (bc.Error == "") {
+				add("error", ac.Error, bc.Error)
+			}
+			continue
+		}
+
+		if ac.Issuer != bc.Issuer {
+			add("issuer", ac.Issuer, bc.Issuer)
+		}
+		if ak, bk := ac.comparedKeyType(), bc.comparedKeyType(); ak != bk {
+			add("keyType", ak, bk)
+		}
+
+		at, aerr := time.Parse(certTimeLayout, ac.NotAfter)
+		bt, berr := time.Parse(certTimeLayout, bc.NotAfter)
+		if aerr == nil && berr == nil {
+			if d := at.Sub(bt); d > ExpiryDriftWindow || d < -ExpiryDriftWindow {
+				add("notAfter", ac.NotAfter, bc.NotAfter)
+			}
+		}
+	}
+	return diffs
+}
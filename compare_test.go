@@ -0,0 +1,120 @@
+package cert
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	staging := LabeledCerts{"staging", Certs{
+		&Cert{DomainName: "a.example.com", Issuer: "Staging CA", PublicKeyAlgorithm: "ECDSA", NotAfter: "2018-01-01 00:00:00 +0000 UTC"},
+		&Cert{DomainName: "b.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", NotAfter: "2018-01-01 00:00:00 +0000 UTC"},
+		&Cert{DomainName: "c.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", NotAfter: "2018-01-01 00:00:00 +0000 UTC"},
+		&Cert{DomainName: "d.example.com", Error: "dial tcp: i/o timeout"},
+		&Cert{DomainName: "e.example.com"},
+		&Cert{DomainName: "g.example.com", Port: "443", Issuer: "R3"},
+		&Cert{DomainName: "g.example.com", Port: "8443", Issuer: "Staging CA"},
+		&Cert{DomainName: "h.example.com", Port: "443", Issuer: "R3"},
+		&Cert{DomainName: "h.example.com", Port: "443", Issuer: "R3"},
+		&Cert{DomainName: "i.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", KeyType: "RSA 2048"},
+		&Cert{DomainName: "j.example.com", Issuer: "R3", PublicKeyAlgorithm: "ECDSA", KeyType: "ECDSA P-256"},
+		&Cert{DomainName: "k.staging.example.com", Issuer: "Staging CA"},
+	}}
+	production := LabeledCerts{"production", Certs{
+		&Cert{DomainName: "a.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", NotAfter: "2018-01-03 09:00:00 +0900 JST"},
+		&Cert{DomainName: "b.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", NotAfter: "2018-03-01 00:00:00 +0000 UTC"},
+		&Cert{DomainName: "c.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", NotAfter: "2018-01-05 00:00:00 +0000 UTC"},
+		&Cert{DomainName: "d.example.com", Issuer: "R3"},
+		&Cert{DomainName: "f.example.com"},
+		&Cert{DomainName: "g.example.com", Port: "443", Issuer: "R3"},
+		&Cert{DomainName: "g.example.com", Port: "8443", Issuer: "R3"},
+		&Cert{DomainName: "h.example.com", Port: "443", Issuer: "R3"},
+		&Cert{DomainName: "i.example.com", Issuer: "R3", PublicKeyAlgorithm: "RSA", KeyType: "RSA 4096"},
+		&Cert{DomainName: "j.example.com", Issuer: "R3", PublicKeyAlgorithm: "ECDSA"},
+		&Cert{DomainName: "k.example.com", Issuer: "R3"},
+	}}
+	CompareHosts = map[string]string{"k.staging.example.com": "k.example.com"}
+	defer func() { CompareHosts = nil }()
+
+	expected := []string{
+		`a.example.com: issuer differs: production "R3" staging "Staging CA"`,
+		`a.example.com: keyType differs: production "RSA" staging "ECDSA"`,
+		`b.example.com: notAfter differs: production "2018-03-01 00:00:00 +0000 UTC" staging "2018-01-01 00:00:00 +0000 UTC"`,
+		`d.example.com: error differs: production "" staging "dial tcp: i/o timeout"`,
+		`e.example.com: presence differs: production "missing" staging "present"`,
+		`f.example.com: presence differs: production "present" staging "missing"`,
+		`g.example.com:8443: issuer differs: production "R3" staging "Staging CA"`,
+		`h.example.com:443: count differs: production "1" staging "2"`,
+		`i.example.com: keyType differs: production "RSA 4096" staging "RSA 2048"`,
+		`j.example.com: keyType differs: production "ECDSA" staging "ECDSA P-256"`,
+		`k.example.com: issuer differs: production "R3" staging "Staging CA"`,
+	}
+
+	diffs := Compare(staging, production)
+	got := make([]string, len(diffs))
+	for i, d := range diffs {
+		got[i] = d.String()
+	}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("unexpected differences\n%q\nwant\n%q", got, expected)
+	}
+}
+
+func TestCompareEqualLabels(t *testing.T) {
+	a := LabeledCerts{"prod", Certs{&Cert{DomainName: "example.com", Issuer: "R3"}}}
+	b := LabeledCerts{"prod", Certs{&Cert{DomainName: "example.com", Issuer: "E1"}}}
+
+	diffs := Compare(a, b)
+	if len(diffs) != 1 || diffs[0].String() != `example.com: issuer differs: prod (1) "R3" prod (2) "E1"` {
+		t.Errorf(`unexpected differences %v`, diffs)
+	}
+}
+
+func TestSetCompareHosts(t *testing.T) {
+	defer func() { CompareHosts = nil }()
+
+	if err := SetCompareHosts("a.staging.example.com=a.example.com,b.staging.example.com=b.example.com"); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if len(CompareHosts) != 2 || CompareHosts["b.staging.example.com"] != "b.example.com" {
+		t.Errorf(`unexpected CompareHosts %v`, CompareHosts)
+	}
+
+	for _, s := range []string{"a.example.com", "=a.example.com", "a.example.com="} {
+		if err := SetCompareHosts(s); err == nil {
+			t.Errorf(`%q: unexpected nil, want error`, s)
+		}
+	}
+}
+
+func TestReadLabeledCerts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "compare")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scan.json")
+	certs := Certs{&Cert{DomainName: "example.com", Issuer: "R3", Findings: []Finding{{ID: "expired", Severity: SeverityCritical}}}}
+	if err := ioutil.WriteFile(path, []byte(certs.JSON()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lc, err := ReadLabeledCerts("staging", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lc.Label != "staging" || len(lc.Certs) != 1 || lc.Certs[0].Issuer != "R3" || lc.Certs[0].Findings[0].Severity != SeverityCritical {
+		t.Errorf(`unexpected LabeledCerts %+v`, lc)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadLabeledCerts("staging", path); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
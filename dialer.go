@@ -0,0 +1,52 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// PreferGo uses Go's built-in DNS resolver instead of the platform's,
+// which may be cgo based, so that scans resolve the same way on every
+// runner.
+var PreferGo = false
+
+// NoProxy makes RDAP, GeoIP and revocation endpoint requests ignore the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+var NoProxy = false
+
+// Network is the network certificates are fetched over: "tcp" for IPv4
+// and IPv6, "tcp4" or "tcp6" for only one of them.
+var Network = "tcp"
+
+func SetNetwork(network string) error {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		Network = network
+		return nil
+	}
+	return fmt.Errorf("Unknown network %q. Use tcp, tcp4 or tcp6.", network)
+}
+
+func resolver() *net.Resolver {
+	if PreferGo {
+		return &net.Resolver{PreferGo: true}
+	}
+	return net.DefaultResolver
+}
+
+func resolverLookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return resolver().LookupSRV(ctx, service, proto, name)
+}
+
+func httpClient(timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	if NoProxy {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.Proxy = nil
+		client.Transport = t
+	}
+	return client
+}
@@ -0,0 +1,63 @@
+package cert
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSetNetwork(t *testing.T) {
+	defer func() { Network = "tcp" }()
+
+	for _, network := range []string{"tcp4", "tcp6", "tcp"} {
+		if err := SetNetwork(network); err != nil || Network != network {
+			t.Errorf(`SetNetwork(%q): unexpected err %v and Network %q`, network, err, Network)
+		}
+	}
+	if err := SetNetwork("udp"); err == nil {
+		t.Error(`SetNetwork("udp"): unexpected nil, want error`)
+	}
+}
+
+func TestNetworkRestrictsDial(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	Network = "tcp6"
+	defer func() { Network = "tcp" }()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	if _, _, err := dialServerCert(target{host: "127.0.0.1", port: port, serverName: "127.0.0.1", scanner: defaultScanner}); err == nil {
+		t.Error(`unexpected nil, want an IPv4 address to be refused over tcp6`)
+	}
+}
+
+func TestResolver(t *testing.T) {
+	if resolver() != net.DefaultResolver {
+		t.Error(`unexpected resolver, want net.DefaultResolver`)
+	}
+
+	PreferGo = true
+	defer func() { PreferGo = false }()
+
+	if !resolver().PreferGo {
+		t.Error(`unexpected resolver, want PreferGo`)
+	}
+}
+
+func TestHTTPClientNoProxy(t *testing.T) {
+	if httpClient(time.Second).Transport != nil {
+		t.Error(`unexpected Transport, want http.DefaultTransport`)
+	}
+
+	NoProxy = true
+	defer func() { NoProxy = false }()
+
+	if tr, ok := httpClient(time.Second).Transport.(*http.Transport); !ok || tr.Proxy != nil {
+		t.Error(`unexpected Transport, want one without a proxy`)
+	}
+}
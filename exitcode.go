@@ -0,0 +1,64 @@
+package cert
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+const (
+	ExitOK       = 0
+	ExitWarning  = 1
+	ExitCritical = 2
+	ExitUnknown  = 3
+)
+
+// exitRank orders exit codes from best to worst. An unknown result
+// outranks a warning but not a critical finding.
+var exitRank = map[int]int{ExitOK: 0, ExitWarning: 1, ExitUnknown: 2, ExitCritical: 3}
+
+func (c *Cert) ExitCode() int {
+	code := ExitOK
+	if c.Error != "" {
+		code = ExitUnknown
+	}
+	for _, f := range c.Findings {
+		switch f.Severity {
+		case SeverityCritical:
+			return ExitCritical
+		case SeverityWarning:
+			if code == ExitOK {
+				code = ExitWarning
+			}
+		}
+	}
+	return code
+}
+
+func (certs Certs) ExitCode() int {
+	code := ExitOK
+	for _, c := range certs {
+		if cc := c.ExitCode(); exitRank[cc] > exitRank[code] {
+			code = cc
+		}
+	}
+	return code
+}
+
+// verificationFinding classifies a failed verification of the served
+// chain as critical, so that it is not mistaken for an unknown result.
+func verificationFinding(err error) (Finding, bool) {
+	var (
+		invalid  x509.CertificateInvalidError
+		unknown  x509.UnknownAuthorityError
+		hostname x509.HostnameError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		return Finding{ID: "invalid", Severity: SeverityCritical, Message: "The certificate is not valid: " + invalid.Error(), Remediation: "renew or reissue the certificate"}, true
+	case errors.As(err, &unknown):
+		return Finding{ID: "unknown-authority", Severity: SeverityCritical, Message: "The certificate is not issued by a trusted CA: " + unknown.Error(), Remediation: "serve the full chain up to a trusted root"}, true
+	case errors.As(err, &hostname):
+		return Finding{ID: "hostname-mismatch", Severity: SeverityCritical, Message: "The certificate does not match the host name: " + hostname.Error(), Remediation: "reissue the certificate for the host name"}, true
+	}
+	return Finding{}, false
+}
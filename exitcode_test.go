@@ -0,0 +1,91 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	var (
+		ok       = &Cert{}
+		info     = &Cert{Findings: []Finding{{Severity: SeverityInfo}}}
+		warning  = &Cert{Findings: []Finding{{Severity: SeverityInfo}, {Severity: SeverityWarning}}}
+		critical = &Cert{Findings: []Finding{{Severity: SeverityWarning}, {Severity: SeverityCritical}}}
+		unknown  = &Cert{Error: "dial tcp: i/o timeout"}
+	)
+
+	var tests = []struct {
+		certs Certs
+		want  int
+	}{
+		{Certs{}, ExitOK},
+		{Certs{ok, info}, ExitOK},
+		{Certs{ok, warning}, ExitWarning},
+		{Certs{warning, unknown}, ExitUnknown},
+		{Certs{unknown, critical, warning}, ExitCritical},
+	}
+
+	for i, test := range tests {
+		if got := test.certs.ExitCode(); got != test.want {
+			t.Errorf(`#%d: unexpected exit code %d, want %d`, i, got, test.want)
+		}
+	}
+}
+
+func TestVerificationFinding(t *testing.T) {
+	var tests = []struct {
+		err error
+		id  string
+	}{
+		{x509.CertificateInvalidError{Reason: x509.Expired}, "invalid"},
+		{&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, "unknown-authority"},
+		{fmt.Errorf("handshake: %w", x509.HostnameError{Certificate: &x509.Certificate{}, Host: "example.com"}), "hostname-mismatch"},
+		{errors.New("dial tcp: i/o timeout"), ""},
+	}
+
+	for _, test := range tests {
+		f, ok := verificationFinding(test.err)
+		if ok != (test.id != "") || f.ID != test.id {
+			t.Errorf(`verificationFinding(%v) = %q, %v, want %q`, test.err, f.ID, ok, test.id)
+			continue
+		}
+		if ok && f.Severity != SeverityCritical {
+			t.Errorf(`unexpected severity %s for %s, want critical`, f.Severity, f.ID)
+		}
+	}
+}
+
+func TestExitCodeVerificationFailure(t *testing.T) {
+	serverCertificate, _ := selfSigned(t, "untrusted.example.com")
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCertificate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.(*tls.Conn).Handshake()
+	}()
+
+	serverCert = dialServerCert
+	defer stubCert()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	c := NewCert("127.0.0.1:" + port)
+
+	if c.Error == "" {
+		t.Fatal(`unexpected empty Cert.Error, want a verification error`)
+	}
+	if got := c.ExitCode(); got != ExitCritical {
+		t.Errorf(`unexpected exit code %d for %q, want %d`, got, c.Error, ExitCritical)
+	}
+}
@@ -0,0 +1,33 @@
+package cert
+
+import "crypto/x509"
+
+// Target is what a Fetcher is asked to connect to.
+type Target struct {
+	Host       string
+	Port       string
+	ServerName string
+}
+
+// Fetcher retrieves the certificate chain a server presents and the IP
+// it was connected on.
+type Fetcher interface {
+	Fetch(t Target) ([]*x509.Certificate, string, error)
+}
+
+type FetcherFunc func(t Target) ([]*x509.Certificate, string, error)
+
+func (f FetcherFunc) Fetch(t Target) ([]*x509.Certificate, string, error) {
+	return f(t)
+}
+
+// CertFetcher, if set, replaces connecting to servers, e.g. with a fake
+// from the certtest package.
+var CertFetcher Fetcher
+
+func fetch(t target) ([]*x509.Certificate, string, error) {
+	if CertFetcher == nil {
+		return serverCert(t)
+	}
+	return CertFetcher.Fetch(Target{Host: t.host, Port: t.port, ServerName: t.serverName})
+}
@@ -0,0 +1,114 @@
+package cert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type GeoIP struct {
+	Country string `json:"country"`
+	ASN     uint   `json:"asn"`
+	ASOrg   string `json:"asOrg"`
+}
+
+type GeoIPProvider interface {
+	LookupIP(ip net.IP) (*GeoIP, error)
+}
+
+var GeoIPLookup GeoIPProvider
+
+var geoIPs = struct {
+	sync.Mutex
+	m map[string]*GeoIP
+}{m: make(map[string]*GeoIP)}
+
+func enrichGeoIP(c *Cert) {
+	ip := net.ParseIP(c.IP)
+	if ip == nil {
+		return
+	}
+
+	geoIPs.Lock()
+	g, ok := geoIPs.m[c.IP]
+	geoIPs.Unlock()
+
+	if !ok {
+		var err error
+		g, err = GeoIPLookup.LookupIP(ip)
+		if err != nil {
+			c.Findings = append(c.Findings, Finding{ID: "geoip-lookup", Severity: SeverityInfo, Message: err.Error()})
+			return
+		}
+
+		geoIPs.Lock()
+		geoIPs.m[c.IP] = g
+		geoIPs.Unlock()
+	}
+
+	c.GeoIP = g
+}
+
+const defaultMaxMindEndpoint = "https://geoip.maxmind.com"
+
+type MaxMind struct {
+	AccountID  string
+	LicenseKey string
+	Endpoint   string
+	// Timeout limits each lookup. Zero means the default of 3 seconds.
+	Timeout time.Duration
+}
+
+type maxMindCity struct {
+	Country struct {
+		ISOCode string `json:"iso_code"`
+	} `json:"country"`
+	Traits struct {
+		ASN   uint   `json:"autonomous_system_number"`
+		ASOrg string `json:"autonomous_system_organization"`
+	} `json:"traits"`
+}
+
+func (m *MaxMind) LookupIP(ip net.IP) (*GeoIP, error) {
+	endpoint := m.Endpoint
+	if endpoint == "" {
+		endpoint = defaultMaxMindEndpoint
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(endpoint, "/")+"/geoip/v2.1/city/"+ip.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(m.AccountID, m.LicenseKey)
+	req.Header.Set("Accept", "application/json")
+
+	timeout := m.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	client := httpClient(timeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GeoIP lookup of %s returned %s.", ip, resp.Status)
+	}
+
+	var city maxMindCity
+	if err := json.NewDecoder(resp.Body).Decode(&city); err != nil {
+		return nil, err
+	}
+
+	return &GeoIP{
+		Country: city.Country.ISOCode,
+		ASN:     city.Traits.ASN,
+		ASOrg:   city.Traits.ASOrg,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package cert
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type geoIPStub struct {
+	lookups int
+	err     error
+}
+
+func (g *geoIPStub) LookupIP(ip net.IP) (*GeoIP, error) {
+	g.lookups++
+	if g.err != nil {
+		return nil, g.err
+	}
+	return &GeoIP{Country: "JP", ASN: 64496, ASOrg: "AS for test"}, nil
+}
+
+func TestGeoIPLookup(t *testing.T) {
+	stub := &geoIPStub{}
+	GeoIPLookup = stub
+	defer func() {
+		GeoIPLookup = nil
+		geoIPs.m = make(map[string]*GeoIP)
+	}()
+
+	c := NewCert("example.com")
+	NewCert("example.org")
+
+	if c.GeoIP == nil || *c.GeoIP != (GeoIP{"JP", 64496, "AS for test"}) {
+		t.Errorf(`unexpected Cert.GeoIP %+v`, c.GeoIP)
+	}
+	if stub.lookups != 1 {
+		t.Errorf(`unexpected lookups %d, want %d`, stub.lookups, 1)
+	}
+	if !strings.Contains(Certs{c}.String(), "GeoIP:      JP AS64496 AS for test\n") {
+		t.Errorf(`GeoIP line was not rendered in %q`, Certs{c}.String())
+	}
+}
+
+func TestGeoIPLookupError(t *testing.T) {
+	GeoIPLookup = &geoIPStub{err: errors.New("quota exceeded")}
+	defer func() { GeoIPLookup = nil }()
+
+	c := NewCert("example.com")
+
+	if c.GeoIP != nil {
+		t.Errorf(`unexpected Cert.GeoIP %+v, want nil`, c.GeoIP)
+	}
+	if countFindings(c, "geoip-lookup") != 1 || c.Findings[0].Message != "quota exceeded" {
+		t.Errorf(`unexpected Cert.Findings %v`, c.Findings)
+	}
+}
+
+func TestMaxMind(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "42" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/geoip/v2.1/city/192.0.2.1" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"country":{"iso_code":"US"},"traits":{"autonomous_system_number":15169,"autonomous_system_organization":"GOOGLE","ip_address":"192.0.2.1"}}`))
+	}))
+	defer ts.Close()
+
+	m := &MaxMind{AccountID: "42", LicenseKey: "secret", Endpoint: ts.URL}
+
+	g, err := m.LookupIP(net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if *g != (GeoIP{"US", 15169, "GOOGLE"}) {
+		t.Errorf(`unexpected GeoIP %+v`, g)
+	}
+
+	m.LicenseKey = "wrong"
+	if _, err := m.LookupIP(net.ParseIP("192.0.2.1")); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
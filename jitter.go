@@ -0,0 +1,57 @@
+package cert
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Shuffle checks targets in random order, so hosts of one cluster listed
+// together are not hit at the same time. Results keep the input order.
+var Shuffle = false
+
+// Jitter, if set, delays each check by a random time up to it.
+var Jitter time.Duration
+
+// RandomSource, if set, is used instead of the global math/rand source
+// for sampling, shuffling and jitter, e.g. to make them reproducible.
+var RandomSource rand.Source
+
+var randMu sync.Mutex
+
+func sourcePerm(n int) []int {
+	if RandomSource == nil {
+		return rand.Perm(n)
+	}
+	randMu.Lock()
+	defer randMu.Unlock()
+	return rand.New(RandomSource).Perm(n)
+}
+
+func sourceInt63n(n int64) int64 {
+	if RandomSource == nil {
+		return rand.Int63n(n)
+	}
+	randMu.Lock()
+	defer randMu.Unlock()
+	return rand.New(RandomSource).Int63n(n)
+}
+
+func checkOrder(n int) []int {
+	if Shuffle {
+		return randPerm(n)
+	}
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	return order
+}
+
+// jitter returns how long to delay a check by.
+func jitter() time.Duration {
+	if Jitter > 0 {
+		return time.Duration(sourceInt63n(int64(Jitter)))
+	}
+	return 0
+}
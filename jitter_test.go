@@ -0,0 +1,73 @@
+package cert
+
+import (
+	"crypto/x509"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShuffle(t *testing.T) {
+	Shuffle = true
+	RandomSource = rand.NewSource(1)
+	defer func() {
+		Shuffle = false
+		RandomSource = nil
+	}()
+
+	var mu sync.Mutex
+	var dialed []string
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		mu.Lock()
+		dialed = append(dialed, tg.host)
+		mu.Unlock()
+		return []*x509.Certificate{{}}, "127.0.0.1", nil
+	}
+	defer stubCert()
+
+	// Checks start in the shuffled order when they run one at a time.
+	s := []string{"a.example", "b.example", "c.example", "d.example", "e.example", "f.example"}
+	tokens = make(chan struct{}, 1)
+	defer func() { tokens = make(chan struct{}, 128) }()
+
+	certs, _ := NewCerts(s)
+
+	for i, c := range certs {
+		if c.DomainName != s[i] {
+			t.Errorf(`unexpected Cert.DomainName %q at %d, want input order`, c.DomainName, i)
+		}
+	}
+	if reflect.DeepEqual(dialed, s) {
+		t.Errorf(`unexpected dial order %v, want shuffled`, dialed)
+	}
+}
+
+func TestCheckOrderIsReproducible(t *testing.T) {
+	Shuffle = true
+	defer func() {
+		Shuffle = false
+		RandomSource = nil
+	}()
+
+	RandomSource = rand.NewSource(42)
+	first := checkOrder(10)
+	RandomSource = rand.NewSource(42)
+	second := checkOrder(10)
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf(`unexpected orders %v and %v, want the same for the same source`, first, second)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	Jitter = 50 * time.Millisecond
+	defer func() { Jitter = 0 }()
+
+	for i := 0; i < 10; i++ {
+		if d := jitter(); d < 0 || d >= Jitter {
+			t.Errorf(`unexpected delay %s, want less than %s`, d, Jitter)
+		}
+	}
+}
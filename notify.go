@@ -0,0 +1,55 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type notifiedFinding struct {
+	Host string `json:"host"`
+	Finding
+}
+
+type notification struct {
+	Text     string            `json:"text"`
+	Findings []notifiedFinding `json:"findings"`
+}
+
+// Notify posts the findings of certs of at least min severity, and failed
+// checks, to a webhook as JSON. Its "text" summary is shown as is by Slack
+// and Mattermost incoming webhooks. Nothing is posted if there is nothing
+// to report.
+func (certs Certs) Notify(url string, min Severity) error {
+	var n notification
+	var lines []string
+	for _, c := range certs {
+		for _, f := range c.reported() {
+			if f.Severity < min {
+				continue
+			}
+			n.Findings = append(n.Findings, notifiedFinding{Host: c.hostPort(), Finding: f})
+			lines = append(lines, fmt.Sprintf("%s [%s] %s: %s", c.hostPort(), f.Severity, f.ID, f.text()))
+		}
+	}
+	if len(n.Findings) == 0 {
+		return nil
+	}
+	n.Text = strings.Join(lines, "\n")
+
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient(defaultScanner.timeout()).Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Notification to %s returned %s.", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	var bodies []notification
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		var n notification
+		if err := json.Unmarshal(b, &n); err != nil {
+			t.Errorf(`unexpected body %q: %v`, b, err)
+		}
+		bodies = append(bodies, n)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	certs := Certs{
+		&Cert{
+			DomainName: "example.com",
+			Port:       "443",
+			Findings: []Finding{
+				{ID: "expired", Severity: SeverityCritical, Message: "Certificate expired.", Remediation: "renew the certificate"},
+				{ID: "weak-key", Severity: SeverityInfo, Message: "Key is short."},
+			},
+		},
+		&Cert{DomainName: "example.org", Error: "dial tcp: i/o timeout"},
+	}
+
+	if err := certs.Notify(ts.URL, SeverityWarning); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if len(bodies) != 1 {
+		t.Fatalf(`unexpected %d notifications, want 1`, len(bodies))
+	}
+	expected := "example.com:443 [critical] expired: Certificate expired. Remediation: renew the certificate\nexample.org [warning] scan-error: dial tcp: i/o timeout"
+	if bodies[0].Text != expected {
+		t.Errorf(`unexpected text %q, want %q`, bodies[0].Text, expected)
+	}
+	if len(bodies[0].Findings) != 2 || bodies[0].Findings[0].Host != "example.com:443" || bodies[0].Findings[0].Severity != SeverityCritical {
+		t.Errorf(`unexpected findings %+v`, bodies[0].Findings)
+	}
+
+	if err := certs[:1].Notify(ts.URL, SeverityCritical+1); err != nil || len(bodies) != 1 {
+		t.Errorf(`unexpected err %v or notification, want nothing posted`, err)
+	}
+
+	status = http.StatusForbidden
+	if err := certs.Notify(ts.URL, SeverityWarning); err == nil || !strings.Contains(err.Error(), "403 Forbidden") {
+		t.Errorf(`unexpected err %v, want the status reported`, err)
+	}
+}
@@ -0,0 +1,123 @@
+package cert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const certTimeLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+var metricLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (c *Cert) metricLabels() string {
+	return fmt.Sprintf(`domain="%s",ip="%s",port="%s"`,
+		metricLabelEscaper.Replace(c.DomainName),
+		metricLabelEscaper.Replace(c.IP),
+		metricLabelEscaper.Replace(c.Port))
+}
+
+// validity returns the validity period of c, parsing it from NotBefore
+// and NotAfter for Certs read back from JSON.
+func (c *Cert) validity() (notBefore, notAfter time.Time, ok bool) {
+	if !c.notBefore.IsZero() && !c.notAfter.IsZero() {
+		return c.notBefore, c.notAfter, true
+	}
+	notBefore, err := time.Parse(certTimeLayout, c.NotBefore)
+	if err != nil {
+		return notBefore, notAfter, false
+	}
+	notAfter, err = time.Parse(certTimeLayout, c.NotAfter)
+	return notBefore, notAfter, err == nil
+}
+
+// metricSeries returns the Certs with distinct labels. The same target
+// given twice would otherwise produce duplicate series, which collectors
+// reject; the first result is kept.
+func (certs Certs) metricSeries() Certs {
+	seen := make(map[string]bool, len(certs))
+	var series Certs
+	for _, c := range certs {
+		if l := c.metricLabels(); !seen[l] {
+			seen[l] = true
+			series = append(series, c)
+		}
+	}
+	return series
+}
+
+func writeTimestampMetric(w io.Writer, name, help string, certs Certs, value func(notBefore, notAfter time.Time) time.Time) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n# UNIT %s seconds\n", name, help, name, name)
+	for _, c := range certs {
+		if c.Error != "" {
+			continue
+		}
+		notBefore, notAfter, ok := c.validity()
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s{%s} %d\n", name, c.metricLabels(), value(notBefore, notAfter).Unix())
+	}
+}
+
+// WriteOpenMetrics writes the validity period, scan errors and findings of
+// certs in the OpenMetrics text format, as read by the textfile collector
+// of node_exporter.
+func (certs Certs) WriteOpenMetrics(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	certs = certs.metricSeries()
+
+	writeTimestampMetric(b, "cert_not_before_timestamp_seconds", "Start of the certificate validity period.", certs, func(notBefore, _ time.Time) time.Time { return notBefore })
+	writeTimestampMetric(b, "cert_not_after_timestamp_seconds", "End of the certificate validity period.", certs, func(_, notAfter time.Time) time.Time { return notAfter })
+
+	fmt.Fprint(b, "# HELP cert_scan_error Whether the certificate could not be checked.\n# TYPE cert_scan_error gauge\n")
+	for _, c := range certs {
+		failed := 0
+		if c.Error != "" {
+			failed = 1
+		}
+		fmt.Fprintf(b, "cert_scan_error{%s} %d\n", c.metricLabels(), failed)
+	}
+
+	fmt.Fprint(b, "# HELP cert_findings Number of findings by severity.\n# TYPE cert_findings gauge\n")
+	for _, c := range certs {
+		counts := make(map[Severity]int)
+		for _, f := range c.Findings {
+			counts[f.Severity]++
+		}
+		for s := range severityNames {
+			fmt.Fprintf(b, "cert_findings{%s,severity=\"%s\"} %d\n", c.metricLabels(), Severity(s), counts[Severity(s)])
+		}
+	}
+
+	fmt.Fprint(b, "# EOF\n")
+	return b.Flush()
+}
+
+// WriteOpenMetricsFile replaces path atomically, so that a collector
+// never reads a partly written file.
+func (certs Certs) WriteOpenMetricsFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := certs.WriteOpenMetrics(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
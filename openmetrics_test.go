@@ -0,0 +1,102 @@
+package cert
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteOpenMetrics(t *testing.T) {
+	certs := Certs{
+		&Cert{
+			DomainName: "example.com",
+			IP:         "127.0.0.1",
+			Port:       "443",
+			NotBefore:  "2017-01-01 00:00:00 +0000 UTC",
+			NotAfter:   "2018-01-01 09:00:00 +0900 JST",
+			Findings:   []Finding{{ID: "expired", Severity: SeverityCritical}},
+			notBefore:  time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			notAfter:   time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		&Cert{
+			DomainName: "example.com",
+			IP:         "127.0.0.1",
+			Port:       "443",
+			Error:      "a duplicate series is dropped",
+		},
+		&Cert{
+			DomainName: "example.org",
+			IP:         "127.0.0.2",
+			Port:       "443",
+			NotBefore:  "2017-01-01 09:00:00 +0900 JST",
+			NotAfter:   "2018-01-01 00:00:00 +0000 UTC",
+		},
+		&Cert{
+			DomainName: `bad"name`,
+			Error:      "dial tcp: i/o timeout",
+		},
+	}
+
+	expected := `# HELP cert_not_before_timestamp_seconds Start of the certificate validity period.
+# TYPE cert_not_before_timestamp_seconds gauge
+# UNIT cert_not_before_timestamp_seconds seconds
+cert_not_before_timestamp_seconds{domain="example.com",ip="127.0.0.1",port="443"} 1483228800
+cert_not_before_timestamp_seconds{domain="example.org",ip="127.0.0.2",port="443"} 1483228800
+# HELP cert_not_after_timestamp_seconds End of the certificate validity period.
+# TYPE cert_not_after_timestamp_seconds gauge
+# UNIT cert_not_after_timestamp_seconds seconds
+cert_not_after_timestamp_seconds{domain="example.com",ip="127.0.0.1",port="443"} 1514764800
+cert_not_after_timestamp_seconds{domain="example.org",ip="127.0.0.2",port="443"} 1514764800
+# HELP cert_scan_error Whether the certificate could not be checked.
+# TYPE cert_scan_error gauge
+cert_scan_error{domain="example.com",ip="127.0.0.1",port="443"} 0
+cert_scan_error{domain="example.org",ip="127.0.0.2",port="443"} 0
+cert_scan_error{domain="bad\"name",ip="",port=""} 1
+# HELP cert_findings Number of findings by severity.
+# TYPE cert_findings gauge
+cert_findings{domain="example.com",ip="127.0.0.1",port="443",severity="info"} 0
+cert_findings{domain="example.com",ip="127.0.0.1",port="443",severity="warning"} 0
+cert_findings{domain="example.com",ip="127.0.0.1",port="443",severity="critical"} 1
+cert_findings{domain="example.org",ip="127.0.0.2",port="443",severity="info"} 0
+cert_findings{domain="example.org",ip="127.0.0.2",port="443",severity="warning"} 0
+cert_findings{domain="example.org",ip="127.0.0.2",port="443",severity="critical"} 0
+cert_findings{domain="bad\"name",ip="",port="",severity="info"} 0
+cert_findings{domain="bad\"name",ip="",port="",severity="warning"} 0
+cert_findings{domain="bad\"name",ip="",port="",severity="critical"} 0
+# EOF
+`
+
+	var b bytes.Buffer
+	if err := certs.WriteOpenMetrics(&b); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if b.String() != expected {
+		t.Errorf(`unexpected output %q, want %q`, b.String(), expected)
+	}
+}
+
+func TestWriteOpenMetricsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "textfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "cert.prom")
+	certs, _ := NewCerts([]string{"example.com"})
+	if err := certs.WriteOpenMetricsFile(path); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "cert.prom" {
+		t.Errorf(`unexpected directory entries %v, want only cert.prom`, entries)
+	}
+	b, _ := ioutil.ReadFile(path)
+	if !bytes.HasSuffix(b, []byte("# EOF\n")) {
+		t.Errorf(`unexpected file content %q`, b)
+	}
+}
@@ -0,0 +1,188 @@
+package cert
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Budget, if set, is the wall-clock time a scan may take. Checks are
+// spread evenly over it, and those scheduled too late to finish in time
+// are reported as skipped instead of being started.
+var Budget time.Duration
+
+type Plan struct {
+	Targets     int
+	Concurrency int
+	Rate        float64
+	Estimate    time.Duration
+}
+
+// checkDuration is how long a check of any of ts may take: the longest
+// handshake timeout, doubled if expired certificates are rechecked, and
+// one lookup timeout for each enrichment and analyzer that goes online.
+func (sc *Scanner) checkDuration(ts []target) time.Duration {
+	lookup := sc.timeout()
+	per := lookup
+	for _, t := range ts {
+		if t.protocol.Timeout > 0 && t.protocol.Timeout > per {
+			per = t.protocol.Timeout
+		}
+	}
+	if RecheckResolver != "" {
+		per *= 2
+	}
+	for _, online := range []bool{ResolveCNAME, RDAP, GeoIPLookup != nil, CheckRevocationEndpoints} {
+		if online {
+			per += lookup
+		}
+	}
+	return per
+}
+
+func PlanScan(targets int, budget time.Duration) Plan {
+	return planScan(targets, budget, defaultScanner.checkDuration(nil))
+}
+
+func planScan(targets int, budget, per time.Duration) Plan {
+	p := Plan{Targets: targets, Concurrency: cap(tokens)}
+	if targets == 0 {
+		return p
+	}
+	if p.Concurrency > targets {
+		p.Concurrency = targets
+	}
+
+	// Starts are spread so that the last check begins one timeout
+	// before the budget runs out.
+	span := budget - per
+	if budget <= 0 || span <= 0 || targets == 1 {
+		p.Estimate = time.Duration((targets+p.Concurrency-1)/p.Concurrency) * per
+		return p
+	}
+
+	// An interval shorter than a nanosecond is over capacity, too.
+	interval := span / time.Duration(targets-1)
+	if interval <= 0 || (per+interval-1)/interval > time.Duration(cap(tokens)) {
+		interval = per / time.Duration(cap(tokens))
+	} else if c := int((per + interval - 1) / interval); c < p.Concurrency {
+		p.Concurrency = c
+	}
+	if interval > 0 {
+		p.Rate = float64(time.Second) / float64(interval)
+	}
+	p.Estimate = time.Duration(targets-1)*interval + per
+	return p
+}
+
+var errBudgetExhausted = errors.New("Skipped, the scan budget is exhausted.")
+
+type limiter struct {
+	scanner  *Scanner
+	per      time.Duration
+	sem      chan struct{}
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+	deadline time.Time
+}
+
+// parseTargets returns the targets of s that parse, to plan their checks.
+func parseTargets(s []string) []target {
+	ts := make([]target, 0, len(s))
+	for _, d := range s {
+		if t, err := parseTarget(d); err == nil {
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+func newLimiter(sc *Scanner, ts []target) *limiter {
+	l := &limiter{scanner: sc}
+	if Budget <= 0 {
+		return l
+	}
+
+	l.per = sc.checkDuration(ts)
+	p := planScan(len(ts), Budget, l.per)
+	l.sem = make(chan struct{}, p.Concurrency)
+	if p.Rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / p.Rate)
+	}
+	l.next = now()
+	l.deadline = l.next.Add(Budget)
+	return l
+}
+
+// reserve returns the next slot to start a check in, or false if a check
+// started in it would not finish before the deadline.
+func (l *limiter) reserve() (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slot := l.next
+	if slot.Add(l.per).After(l.deadline) || !now().Before(l.deadline) {
+		return slot, false
+	}
+	l.next = slot.Add(l.interval)
+	return slot, true
+}
+
+func (l *limiter) check(hostport string) *Cert {
+	t, err := parseTarget(hostport)
+	if err != nil {
+		return &Cert{DomainName: t.serverName, Error: err.Error()}
+	}
+	return l.checkTarget(t)
+}
+
+// wait returns false if the scanner is shut down within d.
+func (l *limiter) wait(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-l.scanner.stopped():
+		return false
+	}
+}
+
+func (l *limiter) checkTarget(t target) *Cert {
+	shutdown := &Cert{DomainName: t.serverName, Error: errShutdown.Error()}
+	stopped := l.scanner.stopped()
+
+	if !l.wait(jitter()) {
+		return shutdown
+	}
+
+	if l.sem != nil {
+		select {
+		case l.sem <- struct{}{}:
+		case <-stopped:
+			return shutdown
+		}
+		defer func() { <-l.sem }()
+
+		start, ok := l.reserve()
+		if !ok {
+			return &Cert{DomainName: t.serverName, Error: errBudgetExhausted.Error()}
+		}
+		if !l.wait(start.Sub(now())) {
+			return shutdown
+		}
+	}
+
+	select {
+	case tokens <- struct{}{}:
+	case <-stopped:
+		return shutdown
+	}
+	defer func() { <-tokens }()
+	return l.scanner.checkTarget(t)
+}
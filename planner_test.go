@@ -0,0 +1,94 @@
+package cert
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanScan(t *testing.T) {
+	var tests = []struct {
+		targets     int
+		budget      time.Duration
+		concurrency int
+		estimate    time.Duration
+	}{
+		{0, time.Minute, 128, 0},
+		{5, 0, 5, 3 * time.Second},
+		{300, 0, 128, 9 * time.Second},
+		{1000, 10 * time.Minute, 6, 10 * time.Minute},
+		{11, 33 * time.Second, 1, 33 * time.Second},
+		{10000, time.Minute, 128, 9999*(3*time.Second/128) + 3*time.Second},
+		{1000, 3*time.Second + 500*time.Nanosecond, 128, 999*(3*time.Second/128) + 3*time.Second},
+	}
+
+	for _, test := range tests {
+		p := PlanScan(test.targets, test.budget)
+		if d := p.Estimate - test.estimate; p.Concurrency != test.concurrency || d < -time.Millisecond || d > time.Millisecond {
+			t.Errorf(`PlanScan(%d, %s) = %+v, want concurrency %d and estimate %s`, test.targets, test.budget, p, test.concurrency, test.estimate)
+		}
+		if test.budget > 0 && p.Estimate > test.budget && p.Concurrency < cap(tokens) {
+			t.Errorf(`PlanScan(%d, %s) = %+v exceeds the budget`, test.targets, test.budget, p)
+		}
+	}
+}
+
+func TestBudgetExhausted(t *testing.T) {
+	Budget = time.Second
+	defer func() { Budget = 0 }()
+
+	certs, _ := NewCerts([]string{"example.com", "example.org"})
+
+	for _, c := range certs {
+		if c.Error != "Skipped, the scan budget is exhausted." {
+			t.Errorf(`unexpected Cert.Error %q for %s, want skipped`, c.Error, c.DomainName)
+		}
+	}
+}
+
+func TestBudgetPacing(t *testing.T) {
+	TimeoutSeconds = 1
+	Budget = 1500 * time.Millisecond
+	defer func() {
+		TimeoutSeconds = 3
+		Budget = 0
+	}()
+
+	start := time.Now()
+	certs, _ := NewCerts([]string{"example.com", "example.org", "example.net"})
+	elapsed := time.Since(start)
+
+	for _, c := range certs {
+		if c.Error != "" {
+			t.Errorf(`unexpected Cert.Error %q for %s`, c.Error, c.DomainName)
+		}
+	}
+	if elapsed < 500*time.Millisecond || elapsed > Budget {
+		t.Errorf(`unexpected elapsed time %s, want checks spread over 500ms`, elapsed)
+	}
+}
+
+func TestCheckDuration(t *testing.T) {
+	sc := NewScanner()
+	sc.Timeout = time.Second
+	slow := target{protocol: Protocol{Timeout: 5 * time.Second}}
+
+	if d := sc.checkDuration(nil); d != time.Second {
+		t.Errorf(`unexpected duration %s, want %s`, d, time.Second)
+	}
+	if d := sc.checkDuration([]target{{}, slow}); d != 5*time.Second {
+		t.Errorf(`unexpected duration %s with a slow protocol, want %s`, d, 5*time.Second)
+	}
+
+	RecheckResolver = "127.0.0.1:53"
+	RDAP = true
+	CheckRevocationEndpoints = true
+	defer func() {
+		RecheckResolver = ""
+		RDAP = false
+		CheckRevocationEndpoints = false
+	}()
+
+	if d := sc.checkDuration([]target{slow}); d != 12*time.Second {
+		t.Errorf(`unexpected duration %s with rechecks and lookups, want %s`, d, 12*time.Second)
+	}
+}
@@ -0,0 +1,206 @@
+package cert
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profile approximates how a kind of client verifies certificates. The
+// built-in profiles do not ship root sets of their own; they overlay
+// exclusions and algorithm restrictions on the local system roots unless
+// SetProfileRoots gives them the roots of the client.
+type Profile struct {
+	// Roots are the trusted roots. nil means the system roots.
+	Roots *x509.CertPool
+	// ExcludedRoots are common names of system roots the client lacks.
+	ExcludedRoots   []string
+	MinRSABits      int
+	AllowEd25519    bool
+	MaxLeafValidity time.Duration
+}
+
+type ProfileResult struct {
+	Profile string `json:"profile"`
+	Valid   bool   `json:"valid"`
+	Error   string `json:"error,omitempty"`
+}
+
+var profiles = struct {
+	sync.RWMutex
+	m map[string]*Profile
+}{m: map[string]*Profile{
+	"modern": {
+		MinRSABits:      2048,
+		MaxLeafValidity: 398 * 24 * time.Hour,
+	},
+	// Android 7.0 has neither the ISRG roots, added in 7.1.1, nor Ed25519
+	// support.
+	"android-7.0": {
+		ExcludedRoots: []string{"ISRG Root X1", "ISRG Root X2"},
+		AllowEd25519:  false,
+	},
+	// Java 8 has neither ISRG Root X2 nor Ed25519 support.
+	"java-8": {
+		ExcludedRoots: []string{"ISRG Root X2"},
+		AllowEd25519:  false,
+	},
+}}
+
+var VerifyProfiles []string
+
+func RegisterProfile(name string, p *Profile) {
+	profiles.Lock()
+	defer profiles.Unlock()
+	profiles.m[name] = p
+}
+
+func LookupProfile(name string) (*Profile, bool) {
+	profiles.RLock()
+	defer profiles.RUnlock()
+	p, ok := profiles.m[name]
+	return p, ok
+}
+
+func SetVerifyProfiles(s string) error {
+	if s == "" {
+		VerifyProfiles = nil
+		return nil
+	}
+
+	names := strings.Split(s, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if _, ok := LookupProfile(names[i]); !ok {
+			return fmt.Errorf("Unknown verification profile %q.", names[i])
+		}
+	}
+	VerifyProfiles = names
+	return nil
+}
+
+// SetProfileRoots replaces the roots of profiles by PEM bundles, given as
+// name=file pairs separated by commas, e.g. the root store a client ships.
+// A name that is not a profile yet adds one trusting only its bundle.
+func SetProfileRoots(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return fmt.Errorf("Invalid profile roots %q. Use name=file.", pair)
+		}
+		name, file := strings.TrimSpace(pair[:i]), strings.TrimSpace(pair[i+1:])
+
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No certificates were found in %s.", file)
+		}
+
+		p := &Profile{}
+		if q, ok := LookupProfile(name); ok {
+			copied := *q
+			p = &copied
+		}
+		p.Roots = roots
+		RegisterProfile(name, p)
+	}
+	return nil
+}
+
+func (p *Profile) check(chain []*x509.Certificate) error {
+	root := chain[len(chain)-1]
+	for _, cn := range p.ExcludedRoots {
+		if root.Subject.CommonName == cn {
+			return fmt.Errorf("Root %s is not trusted.", cn)
+		}
+	}
+
+	// MD5 and SHA-1 signatures are already refused by Verify.
+	for _, c := range chain[:len(chain)-1] {
+		if c.SignatureAlgorithm == x509.PureEd25519 && !p.AllowEd25519 {
+			return fmt.Errorf("%s is signed with Ed25519.", c.Subject.CommonName)
+		}
+
+		switch k := c.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if bits := k.N.BitLen(); bits < p.MinRSABits {
+				return fmt.Errorf("%s has a %d bit RSA key.", c.Subject.CommonName, bits)
+			}
+		case ed25519.PublicKey:
+			if !p.AllowEd25519 {
+				return fmt.Errorf("%s has an Ed25519 key.", c.Subject.CommonName)
+			}
+		}
+	}
+
+	leaf := chain[0]
+	if p.MaxLeafValidity > 0 && leaf.NotAfter.Sub(leaf.NotBefore) > p.MaxLeafValidity {
+		return fmt.Errorf("Certificate is valid for longer than %d days.", p.MaxLeafValidity/(24*time.Hour))
+	}
+	return nil
+}
+
+func (p *Profile) verify(certChain []*x509.Certificate, name string) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range certChain[1:] {
+		intermediates.AddCert(c)
+	}
+
+	chains, err := certChain[0].Verify(x509.VerifyOptions{
+		DNSName:       name,
+		Roots:         p.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	// The client accepts the certificate if any of the chains it could
+	// build satisfies its restrictions.
+	for _, chain := range chains {
+		if err = p.check(chain); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func verifyProfiles(c *Cert, certChain []*x509.Certificate) []ProfileResult {
+	var results []ProfileResult
+	for _, name := range VerifyProfiles {
+		p, ok := LookupProfile(name)
+		if !ok {
+			continue
+		}
+		r := ProfileResult{Profile: name, Valid: true}
+		if err := p.verify(certChain, c.DomainName); err != nil {
+			r.Valid = false
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func analyzeProfiles(c *Cert) []Finding {
+	var findings []Finding
+	for _, r := range c.Profiles {
+		if !r.Valid {
+			findings = append(findings, Finding{ID: "profile", Severity: SeverityWarning, Message: fmt.Sprintf("Not valid for %s clients: %s", r.Profile, r.Error), Remediation: "serve a chain " + r.Profile + " clients trust"})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,210 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProfileVerify(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	var tests = []struct {
+		profile *Profile
+		name    string
+		valid   bool
+	}{
+		{&Profile{Roots: roots}, "example.com", true},
+		{&Profile{Roots: roots}, "www.example.com", false},
+		{&Profile{}, "example.com", false},
+		{&Profile{Roots: roots, ExcludedRoots: []string{"Test CA"}}, "example.com", false},
+		{&Profile{Roots: roots, MaxLeafValidity: time.Hour}, "example.com", false},
+	}
+
+	for i, test := range tests {
+		err := test.profile.verify([]*x509.Certificate{leaf, ca.cert}, test.name)
+		if (err == nil) != test.valid {
+			t.Errorf(`#%d: unexpected err %v, want valid %v`, i, err, test.valid)
+		}
+	}
+}
+
+func TestVerifyProfiles(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	RegisterProfile("test-trusting", &Profile{Roots: roots})
+	RegisterProfile("test-distrusting", &Profile{Roots: roots, ExcludedRoots: []string{"Test CA"}})
+	defer func() {
+		profiles.Lock()
+		delete(profiles.m, "test-trusting")
+		delete(profiles.m, "test-distrusting")
+		profiles.Unlock()
+		VerifyProfiles = nil
+	}()
+
+	if err := SetVerifyProfiles("test-trusting, test-distrusting"); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		return []*x509.Certificate{leaf, ca.cert}, "127.0.0.1", nil
+	}
+	defer stubCert()
+
+	c := NewCert("example.com")
+
+	if len(c.Profiles) != 2 || !c.Profiles[0].Valid || c.Profiles[1].Valid {
+		t.Errorf(`unexpected Cert.Profiles %+v`, c.Profiles)
+	}
+	if countFindings(c, "profile") != 1 {
+		t.Errorf(`unexpected Cert.Findings %v, want 1 profile finding`, c.Findings)
+	}
+}
+
+func TestSetVerifyProfilesError(t *testing.T) {
+	defer func() { VerifyProfiles = nil }()
+
+	if err := SetVerifyProfiles("modern,windows-xp"); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
+
+func issueWithKey(t *testing.T, ca *testCA, pub crypto.PublicKey, validity time.Duration) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validity - time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := x509.ParseCertificate(der)
+	return c
+}
+
+func TestBuiltinProfiles(t *testing.T) {
+	isrgX1 := newTestCA(t, "ISRG Root X1")
+	isrgX2 := newTestCA(t, "ISRG Root X2")
+	other := newTestCA(t, "Other Root")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	day := 24 * time.Hour
+	var tests = []struct {
+		profile string
+		chain   []*x509.Certificate
+		valid   bool
+	}{
+		{"modern", []*x509.Certificate{issueWithKey(t, isrgX1, &ecKey.PublicKey, 90*day), isrgX1.cert}, true},
+		{"modern", []*x509.Certificate{issueWithKey(t, other, &ecKey.PublicKey, 400*day), other.cert}, false},
+		{"modern", []*x509.Certificate{issueWithKey(t, other, &rsaKey.PublicKey, 90*day), other.cert}, false},
+		{"modern", []*x509.Certificate{issueWithKey(t, other, edKey, 90*day), other.cert}, false},
+		{"android-7.0", []*x509.Certificate{issueWithKey(t, other, &ecKey.PublicKey, 400*day), other.cert}, true},
+		{"android-7.0", []*x509.Certificate{issueWithKey(t, isrgX1, &ecKey.PublicKey, 90*day), isrgX1.cert}, false},
+		{"android-7.0", []*x509.Certificate{issueWithKey(t, isrgX2, &ecKey.PublicKey, 90*day), isrgX2.cert}, false},
+		{"android-7.0", []*x509.Certificate{issueWithKey(t, other, edKey, 90*day), other.cert}, false},
+		{"java-8", []*x509.Certificate{issueWithKey(t, isrgX1, &ecKey.PublicKey, 400*day), isrgX1.cert}, true},
+		{"java-8", []*x509.Certificate{issueWithKey(t, isrgX2, &ecKey.PublicKey, 90*day), isrgX2.cert}, false},
+		{"java-8", []*x509.Certificate{issueWithKey(t, other, edKey, 90*day), other.cert}, false},
+	}
+
+	for i, test := range tests {
+		p, ok := LookupProfile(test.profile)
+		if !ok {
+			t.Fatalf(`profile %s is not built in`, test.profile)
+		}
+		if err := p.check(test.chain); (err == nil) != test.valid {
+			t.Errorf(`#%d %s: unexpected err %v, want valid %v`, i, test.profile, err, test.valid)
+		}
+	}
+}
+
+func TestSetProfileRoots(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	leaf := ca.issue(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+
+	f, err := ioutil.TempFile("", "roots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	f.Close()
+
+	java8, _ := LookupProfile("java-8")
+	defer func() {
+		profiles.Lock()
+		profiles.m["java-8"] = java8
+		delete(profiles.m, "test-corp")
+		profiles.Unlock()
+	}()
+
+	if err := SetProfileRoots("java-8=" + f.Name() + ",test-corp=" + f.Name()); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	p, _ := LookupProfile("java-8")
+	if err := p.verify([]*x509.Certificate{leaf, ca.cert}, "example.com"); err != nil {
+		t.Errorf(`unexpected err %s for java-8 with the loaded roots, want nil`, err.Error())
+	}
+	if len(p.ExcludedRoots) != 1 || java8.Roots != nil {
+		t.Errorf(`unexpected java-8 profile %+v, want the built-in one with the loaded roots only`, p)
+	}
+	if p, ok := LookupProfile("test-corp"); !ok || p.verify([]*x509.Certificate{leaf, ca.cert}, "example.com") != nil {
+		t.Error(`test-corp was not added trusting the loaded roots`)
+	}
+
+	for _, s := range []string{"java-8", "java-8=/nonexistent/roots.pem", "java-8=" + os.DevNull} {
+		if err := SetProfileRoots(s); err == nil {
+			t.Errorf(`SetProfileRoots(%q): unexpected nil, want error`, s)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package cert
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+type Protocol struct {
+	Port     string
+	SRV      string
+	ALPN     []string
+	Timeout  time.Duration
+	StartTLS func(conn net.Conn, serverName string) error
+}
+
+var protocols = struct {
+	sync.RWMutex
+	m map[string]Protocol
+}{m: map[string]Protocol{
+	"":            {Port: defaultPort},
+	"https":       {Port: "443"},
+	"xmpp":        {Port: "5222", SRV: "xmpp-client", StartTLS: xmppStartTLS("jabber:client")},
+	"xmpp-server": {Port: "5269", SRV: "xmpp-server", StartTLS: xmppStartTLS("jabber:server")},
+	"sips":        {Port: "5061", SRV: "sips"},
+	"rtsps":       {Port: "322"},
+	"mqtts":       {Port: "8883"},
+	"kafka":       {Port: "9093"},
+	"amqps":       {Port: "5671"},
+}}
+
+func RegisterProtocol(scheme string, p Protocol) error {
+	scheme = strings.ToLower(scheme)
+	if scheme == "" || strings.Contains(scheme, "://") {
+		return fmt.Errorf("Invalid scheme %q.", scheme)
+	}
+	if port, err := strconv.Atoi(p.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("Invalid default port %q for scheme %q.", p.Port, scheme)
+	}
+
+	protocols.Lock()
+	defer protocols.Unlock()
+
+	protocols.m[scheme] = p
+
+	return nil
+}
+
+func LookupProtocol(scheme string) (Protocol, bool) {
+	protocols.RLock()
+	defer protocols.RUnlock()
+
+	p, ok := protocols.m[strings.ToLower(scheme)]
+	return p, ok
+}
+
+// servicePorts are the service names of TLS ports known without a
+// services database, which minimal containers lack.
+var servicePorts = map[string]int{
+	"https":       443,
+	"smtps":       465,
+	"submissions": 465,
+	"submission":  587,
+	"ldaps":       636,
+	"ftps":        990,
+	"imaps":       993,
+	"pop3s":       995,
+	"sip-tls":     5061,
+	"sips":        5061,
+	"xmpp-client": 5222,
+	"xmpp-server": 5269,
+	"amqps":       5671,
+	"ircs-u":      6697,
+	"secure-mqtt": 8883,
+}
+
+// lookupPort resolves port numbers and service names the way net.Dial
+// does, checking servicePorts first.
+func lookupPort(port string) (int, error) {
+	if n, ok := servicePorts[strings.ToLower(port)]; ok {
+		return n, nil
+	}
+	return net.LookupPort("tcp", port)
+}
+
+func parseTarget(s string) (target, error) {
+	scheme := ""
+	if i := strings.Index(s, "://"); i >= 0 {
+		scheme, s = strings.ToLower(s[:i]), s[i+len("://"):]
+	}
+
+	p, ok := LookupProtocol(scheme)
+	if !ok {
+		return target{serverName: s}, fmt.Errorf("Unsupported scheme %q.", scheme)
+	}
+
+	host, port, err := splitHostPort(s, p.Port)
+	if err != nil {
+		return target{serverName: host}, err
+	}
+	n, err := lookupPort(port)
+	if err != nil || n < 1 {
+		return target{serverName: host}, fmt.Errorf("Invalid port %q.", port)
+	}
+	port = strconv.Itoa(n)
+	if err := checkHostName(host); err != nil {
+		return target{serverName: host}, err
+	}
+
+	return target{
+		host:         host,
+		port:         port,
+		explicitPort: strings.Contains(s, ":"),
+		serverName:   host,
+		protocol:     p,
+		scanner:      defaultScanner,
+	}, nil
+}
+
+func checkHostName(host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if host == "" || len(host) > 253 {
+		return fmt.Errorf("Invalid host name %q.", host)
+	}
+
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("Invalid host name %q.", host)
+		}
+		for _, r := range label {
+			if r != '-' && r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+				return fmt.Errorf("Invalid character %q in host name %q.", r, host)
+			}
+		}
+	}
+	return nil
+}
+
+func resolveService(t target) target {
+	if t.protocol.SRV == "" || t.explicitPort {
+		return t
+	}
+
+	_, addrs, err := t.scanner.lookupSRV(t.protocol.SRV, "tcp", t.host)
+	if err != nil || len(addrs) == 0 || addrs[0].Target == "." {
+		return t
+	}
+
+	t.host = strings.TrimSuffix(addrs[0].Target, ".")
+	t.port = strconv.Itoa(int(addrs[0].Port))
+	return t
+}
@@ -0,0 +1,277 @@
+package cert
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	type want struct {
+		host       string
+		port       string
+		serverName string
+		startTLS   bool
+		err        string
+	}
+	var tests = []struct {
+		input string
+		want  want
+	}{
+		{"example.com", want{"example.com", "443", "example.com", false, ""}},
+		{"https://example.com", want{"example.com", "443", "example.com", false, ""}},
+		{"example.com:8443", want{"example.com", "8443", "example.com", false, ""}},
+		{"example.com:https", want{"example.com", "443", "example.com", false, ""}},
+		{"imap.example.com:imaps", want{"imap.example.com", "993", "imap.example.com", false, ""}},
+		{"mail.example.com:SMTPS", want{"mail.example.com", "465", "mail.example.com", false, ""}},
+		{"ldap.example.com:ldaps", want{"ldap.example.com", "636", "ldap.example.com", false, ""}},
+		{"example.com:nosuchservice", want{"", "", "example.com", false, `Invalid port "nosuchservice".`}},
+		{"example.com:0", want{"", "", "example.com", false, `Invalid port "0".`}},
+		{"xmpp://example.com", want{"example.com", "5222", "example.com", true, ""}},
+		{"XMPP://example.com:5223", want{"example.com", "5223", "example.com", true, ""}},
+		{"xmpp-server://example.com", want{"example.com", "5269", "example.com", true, ""}},
+		{"sips://example.com", want{"example.com", "5061", "example.com", false, ""}},
+		{"rtsps://192.0.2.10", want{"192.0.2.10", "322", "192.0.2.10", false, ""}},
+		{"rtsps://camera.example.com:8322", want{"camera.example.com", "8322", "camera.example.com", false, ""}},
+		{"mqtts://broker.example.com", want{"broker.example.com", "8883", "broker.example.com", false, ""}},
+		{"kafka://broker1.example.com", want{"broker1.example.com", "9093", "broker1.example.com", false, ""}},
+		{"amqps://mq.example.com", want{"mq.example.com", "5671", "mq.example.com", false, ""}},
+		{"gopher://example.com", want{"", "", "example.com", false, `Unsupported scheme "gopher".`}},
+	}
+
+	for _, test := range tests {
+		tg, err := parseTarget(test.input)
+		errMsg := ""
+		if err != nil {
+			errMsg = err.Error()
+		}
+		got := want{tg.host, tg.port, tg.serverName, tg.protocol.StartTLS != nil, errMsg}
+		if got != test.want {
+			t.Errorf("parseTarget(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestResolveService(t *testing.T) {
+	lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if proto != "tcp" || name != "example.com" {
+			return "", nil, &net.DNSError{Err: "no such host", Name: name}
+		}
+		switch service {
+		case "xmpp-client":
+			return "_xmpp-client._tcp.example.com.", []*net.SRV{&net.SRV{Target: "chat.example.net.", Port: 5223}}, nil
+		case "sips":
+			return "_sips._tcp.example.com.", []*net.SRV{&net.SRV{Target: "sip.example.net.", Port: 5061}}, nil
+		}
+		return "", nil, &net.DNSError{Err: "no such host", Name: name}
+	}
+	defer func() { lookupSRV = resolverLookupSRV }()
+
+	var tests = []struct {
+		input string
+		host  string
+		port  string
+	}{
+		{"xmpp://example.com", "chat.example.net", "5223"},
+		{"xmpp://example.com:5222", "example.com", "5222"},
+		{"xmpp://example.org", "example.org", "5222"},
+		{"example.com", "example.com", "443"},
+		{"sips://example.com", "sip.example.net", "5061"},
+	}
+
+	for _, test := range tests {
+		tg, _ := parseTarget(test.input)
+		tg = resolveService(tg)
+
+		if tg.host != test.host || tg.port != test.port {
+			t.Errorf("resolveService(%q) dials %s:%s, want %s:%s", test.input, tg.host, tg.port, test.host, test.port)
+		}
+		if tg.serverName != "example.com" && tg.serverName != "example.org" {
+			t.Errorf("resolveService(%q) changed server name to %q", test.input, tg.serverName)
+		}
+	}
+}
+
+func TestRegisterProtocol(t *testing.T) {
+	var greeted string
+	p := Protocol{
+		Port: "7443",
+		ALPN: []string{"inhouse/1"},
+		StartTLS: func(conn net.Conn, serverName string) error {
+			greeted = serverName
+			return nil
+		},
+	}
+	if err := RegisterProtocol("InHouse", p); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	defer func() {
+		protocols.Lock()
+		delete(protocols.m, "inhouse")
+		protocols.Unlock()
+	}()
+
+	tg, err := parseTarget("inhouse://svc.example.com")
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if tg.port != "7443" {
+		t.Errorf(`unexpected port %q, want %q`, tg.port, "7443")
+	}
+	if len(tg.protocol.ALPN) != 1 || tg.protocol.ALPN[0] != "inhouse/1" {
+		t.Errorf(`unexpected ALPN %v`, tg.protocol.ALPN)
+	}
+	tg.protocol.StartTLS(nil, tg.serverName)
+	if greeted != "svc.example.com" {
+		t.Errorf(`StartTLS was called with %q, want %q`, greeted, "svc.example.com")
+	}
+}
+
+func TestRegisterProtocolError(t *testing.T) {
+	var tests = []struct {
+		scheme string
+		p      Protocol
+	}{
+		{"", Protocol{Port: "443"}},
+		{"a://b", Protocol{Port: "443"}},
+		{"inhouse", Protocol{}},
+	}
+
+	for _, test := range tests {
+		if err := RegisterProtocol(test.scheme, test.p); err == nil {
+			t.Errorf(`RegisterProtocol(%q, %+v): unexpected nil, want error`, test.scheme, test.p)
+		}
+	}
+}
+
+var dialServerCert = serverCert
+
+func selfSigned(t *testing.T, cn string) (tls.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	c, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, append(certPEM, keyPEM...)
+}
+
+func TestMQTTClientCertificateAndALPN(t *testing.T) {
+	serverCertificate, _ := selfSigned(t, "broker.example.com")
+	_, clientPEM := selfSigned(t, "device")
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCertificate},
+		ClientAuth:   tls.RequireAnyClientCert,
+		NextProtos:   []string{"mqtt"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type handshake struct {
+		proto   string
+		clients int
+	}
+	result := make(chan handshake, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tlsConn := conn.(*tls.Conn)
+			tlsConn.Handshake()
+			state := tlsConn.ConnectionState()
+			conn.Close()
+			result <- handshake{state.NegotiatedProtocol, len(state.PeerCertificates)}
+		}
+	}()
+
+	f, err := ioutil.TempFile("", "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(clientPEM)
+	f.Close()
+
+	if err := SetClientCertificate(f.Name(), ""); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	serverCert = dialServerCert
+	SkipVerify = true
+	defer func() {
+		ClientCertificate = nil
+		SkipVerify = false
+		ALPN = nil
+		stubCert()
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	c := NewCert("mqtts://127.0.0.1:" + port)
+
+	if c.Error != "" {
+		t.Fatalf(`unexpected Cert.Error %q`, c.Error)
+	}
+	if c.CommonName != "broker.example.com" {
+		t.Errorf(`unexpected Cert.CommonName %q, want %q`, c.CommonName, "broker.example.com")
+	}
+
+	h := <-result
+	if h.proto != "" {
+		t.Errorf(`unexpected negotiated protocol %q, want none offered by default`, h.proto)
+	}
+	if h.clients != 1 {
+		t.Errorf(`unexpected client certificates %d, want %d`, h.clients, 1)
+	}
+
+	ALPN = []string{"mqtt"}
+	if c := NewCert("mqtts://127.0.0.1:" + port); c.Error != "" {
+		t.Fatalf(`unexpected Cert.Error %q`, c.Error)
+	}
+	if h := <-result; h.proto != "mqtt" {
+		t.Errorf(`unexpected negotiated protocol %q, want %q`, h.proto, "mqtt")
+	}
+}
+
+func TestSetClientCertificateError(t *testing.T) {
+	if err := SetClientCertificate("/nonexistent/client.pem", ""); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+	if ClientCertificate != nil {
+		t.Error(`unexpected ClientCertificate, want nil`)
+	}
+}
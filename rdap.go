@@ -0,0 +1,202 @@
+package cert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+var RDAP = false
+
+var RDAPServer = "https://rdap.org"
+
+type rdapEntity struct {
+	Roles      []string      `json:"roles"`
+	VCardArray []interface{} `json:"vcardArray"`
+	Entities   []rdapEntity  `json:"entities"`
+}
+
+type rdapDomain struct {
+	Entities []rdapEntity `json:"entities"`
+}
+
+type rdapOwner struct {
+	registrar     string
+	registrantOrg string
+	err           error
+}
+
+// rdapErrorTTL is how long a failed lookup is reused before the domain is
+// looked up again.
+const rdapErrorTTL = 5 * time.Minute
+
+// rdapResult is the cached lookup of one domain, including failed ones
+// until they expire. done is closed once the lookup has finished.
+type rdapResult struct {
+	done    chan struct{}
+	owner   *rdapOwner
+	found   bool
+	err     error
+	expires time.Time
+}
+
+var rdapOwners = struct {
+	sync.Mutex
+	m map[string]*rdapResult
+}{m: make(map[string]*rdapResult)}
+
+func (e rdapEntity) hasRole(role string) bool {
+	for _, r := range e.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (e rdapEntity) vcard(name string) string {
+	if len(e.VCardArray) < 2 {
+		return ""
+	}
+	props, ok := e.VCardArray[1].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if !ok || len(prop) < 4 || prop[0] != name {
+			continue
+		}
+		if v, ok := prop[3].(string); ok {
+			return v
+		}
+	}
+	return ""
+}
+
+func lookupRDAP(domain string, timeout time.Duration) (*rdapOwner, bool, error) {
+	client := httpClient(timeout)
+
+	req, err := http.NewRequest("GET", strings.TrimSuffix(RDAPServer, "/")+"/domain/"+domain, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("RDAP lookup of %s returned %s.", domain, resp.Status)
+	}
+
+	var d rdapDomain
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return nil, false, err
+	}
+
+	owner := &rdapOwner{}
+	for _, e := range d.Entities {
+		switch {
+		case e.hasRole("registrar"):
+			owner.registrar = e.vcard("fn")
+		case e.hasRole("registrant"):
+			owner.registrantOrg = e.vcard("org")
+			if owner.registrantOrg == "" {
+				owner.registrantOrg = e.vcard("fn")
+			}
+		}
+	}
+	return owner, true, nil
+}
+
+// cachedRDAP looks domain up once and shares the result with concurrent
+// and later callers. A failed lookup is repeated once it expires.
+func cachedRDAP(domain string, timeout time.Duration) (*rdapOwner, bool, error) {
+	rdapOwners.Lock()
+	r, ok := rdapOwners.m[domain]
+	if ok {
+		select {
+		case <-r.done:
+			ok = r.err == nil || now().Before(r.expires)
+		default:
+		}
+	}
+	if !ok {
+		r = &rdapResult{done: make(chan struct{})}
+		rdapOwners.m[domain] = r
+	}
+	rdapOwners.Unlock()
+
+	if ok {
+		<-r.done
+		return r.owner, r.found, r.err
+	}
+
+	r.owner, r.found, r.err = lookupRDAP(domain, timeout)
+	if r.err != nil {
+		r.expires = now().Add(rdapErrorTTL)
+	}
+	close(r.done)
+	return r.owner, r.found, r.err
+}
+
+func rdapOwnerOf(host string, timeout time.Duration) *rdapOwner {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return &rdapOwner{err: fmt.Errorf("No registrable domain was found for %s.", host)}
+	}
+
+	// Start at the registrable domain and walk towards the full host name,
+	// as some registries delegate below the public suffix list. A failed
+	// candidate does not stop the walk.
+	var subLabels []string
+	if host != domain {
+		subLabels = strings.Split(strings.TrimSuffix(host, "."+domain), ".")
+	}
+	var lastErr error
+	name := domain
+	for i := len(subLabels); i >= 0; i-- {
+		if i < len(subLabels) {
+			name = subLabels[i] + "." + name
+		}
+		owner, found, err := cachedRDAP(name, timeout)
+		if found {
+			return owner
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return &rdapOwner{err: lastErr}
+	}
+	return &rdapOwner{err: fmt.Errorf("No RDAP record was found for %s.", host)}
+}
+
+func enrichRDAP(c *Cert) {
+	if net.ParseIP(c.DomainName) != nil || !strings.Contains(c.DomainName, ".") {
+		return
+	}
+
+	owner := rdapOwnerOf(c.DomainName, c.timeout)
+	if owner.err != nil {
+		c.Findings = append(c.Findings, Finding{ID: "rdap-lookup", Severity: SeverityInfo, Message: owner.err.Error()})
+		return
+	}
+	c.Registrar = owner.registrar
+	c.RegistrantOrg = owner.registrantOrg
+}
@@ -0,0 +1,208 @@
+package cert
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const rdapResponse = `{
+  "objectClassName": "domain",
+  "ldhName": "example.com",
+  "entities": [
+    {
+      "roles": ["registrar"],
+      "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["fn", {}, "text", "Registrar for test"]]]
+    },
+    {
+      "roles": ["registrant"],
+      "vcardArray": ["vcard", [["version", {}, "text", "4.0"], ["org", {}, "text", "Example Inc."]]]
+    }
+  ]
+}`
+
+func TestRDAP(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/domain/example.co.jp" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		w.Write([]byte(rdapResponse))
+	}))
+	defer ts.Close()
+
+	RDAP = true
+	RDAPServer = ts.URL
+	defer func() {
+		RDAP = false
+		RDAPServer = "https://rdap.org"
+		rdapOwners.m = make(map[string]*rdapResult)
+	}()
+
+	c := NewCert("www.example.co.jp")
+
+	if c.Registrar != "Registrar for test" {
+		t.Errorf(`unexpected Cert.Registrar %q, want %q`, c.Registrar, "Registrar for test")
+	}
+	if c.RegistrantOrg != "Example Inc." {
+		t.Errorf(`unexpected Cert.RegistrantOrg %q, want %q`, c.RegistrantOrg, "Example Inc.")
+	}
+	if strings.Join(paths, ",") != "/domain/example.co.jp" {
+		t.Errorf(`unexpected RDAP queries %v`, paths)
+	}
+
+	paths = nil
+	NewCert("mail.example.co.jp")
+
+	if len(paths) != 0 {
+		t.Errorf(`unexpected RDAP queries %v, want cached results`, paths)
+	}
+}
+
+func TestRDAPError(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	RDAP = true
+	RDAPServer = ts.URL
+	defer func() {
+		RDAP = false
+		RDAPServer = "https://rdap.org"
+		rdapOwners.m = make(map[string]*rdapResult)
+	}()
+
+	c := NewCert("example.com")
+
+	if c.Registrar != "" {
+		t.Errorf(`unexpected Cert.Registrar %q, want empty`, c.Registrar)
+	}
+	if countFindings(c, "rdap-lookup") != 1 {
+		t.Errorf(`unexpected Cert.Findings %v, want 1 rdap-lookup finding`, c.Findings)
+	}
+
+	c = NewCert("www.example.com")
+
+	if countFindings(c, "rdap-lookup") != 1 {
+		t.Errorf(`unexpected Cert.Findings %v, want 1 rdap-lookup finding`, c.Findings)
+	}
+	if strings.Join(paths, ",") != "/domain/example.com,/domain/www.example.com" {
+		t.Errorf(`unexpected RDAP queries %v, want the failed one cached`, paths)
+	}
+
+	expired := time.Now().Add(rdapErrorTTL + time.Second)
+	now = func() time.Time { return expired }
+	defer func() { now = time.Now }()
+	paths = nil
+
+	NewCert("example.com")
+
+	if strings.Join(paths, ",") != "/domain/example.com" {
+		t.Errorf(`unexpected RDAP queries %v, want the expired failure looked up again`, paths)
+	}
+}
+
+func TestRDAPStartsAtRegistrableDomain(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/domain/shop.example.co.uk" {
+			http.Error(w, "not a domain", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(rdapResponse))
+	}))
+	defer ts.Close()
+
+	RDAPServer = ts.URL
+	defer func() {
+		RDAPServer = "https://rdap.org"
+		rdapOwners.m = make(map[string]*rdapResult)
+	}()
+
+	if owner := rdapOwnerOf("www.shop.example.co.uk", time.Second); owner.err != nil || owner.registrar != "Registrar for test" {
+		t.Errorf(`unexpected owner %+v, want Registrar for test`, owner)
+	}
+	if strings.Join(paths, ",") != "/domain/example.co.uk,/domain/shop.example.co.uk" {
+		t.Errorf(`unexpected RDAP queries %v, want none for public suffixes`, paths)
+	}
+
+	paths = nil
+	if owner := rdapOwnerOf("co.uk", time.Second); owner.err == nil || len(paths) != 0 {
+		t.Errorf(`unexpected owner %+v and RDAP queries %v for a public suffix, want an error and none`, owner, paths)
+	}
+}
+
+func TestRDAPConcurrentLookups(t *testing.T) {
+	var mu sync.Mutex
+	queries := make(map[string]int)
+	both := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries[r.URL.Path]++
+		if len(queries) == 2 && queries[r.URL.Path] == 1 {
+			close(both)
+		}
+		mu.Unlock()
+
+		// Answer only once lookups of both domains are in flight.
+		select {
+		case <-both:
+		case <-time.After(time.Second):
+			http.Error(w, "lookups are serialized", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(rdapResponse))
+	}))
+	defer ts.Close()
+
+	RDAPServer = ts.URL
+	defer func() {
+		RDAPServer = "https://rdap.org"
+		rdapOwners.m = make(map[string]*rdapResult)
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			if owner := rdapOwnerOf(host, time.Second); owner.err != nil {
+				errs <- owner.err
+			}
+		}(fmt.Sprintf("host%d.example%d.com", i, i%2))
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(queries) != 2 || queries["/domain/example0.com"] != 1 || queries["/domain/example1.com"] != 1 {
+		t.Errorf(`unexpected RDAP queries %v, want one per domain`, queries)
+	}
+}
+
+func TestRDAPSkipsIPAddresses(t *testing.T) {
+	c := &Cert{DomainName: "192.0.2.1"}
+
+	enrichRDAP(c)
+
+	if len(c.Findings) != 0 {
+		t.Errorf(`unexpected Cert.Findings %v, want none`, c.Findings)
+	}
+}
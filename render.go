@@ -0,0 +1,141 @@
+package cert
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+type column struct {
+	name  string
+	value string
+}
+
+var (
+	domainColumn    = column{"DomainName", "{{.DomainName}}"}
+	ipColumn        = column{"IP", "{{.IP}}"}
+	issuerColumn    = column{"Issuer", "{{.Issuer}}"}
+	notBeforeColumn = column{"NotBefore", "{{.NotBefore}}"}
+	notAfterColumn  = column{"NotAfter", "{{.NotAfter}}"}
+	errorColumn     = column{"Error", "{{.Error}}"}
+	findingsColumn  = column{"Findings", `{{range $i, $f := .Findings}}{{if $i}}; {{end}}{{$f.Severity}}: {{$f.Message}}{{end}}`}
+)
+
+var layouts = map[string][]column{
+	"summary": {domainColumn, issuerColumn, notAfterColumn, errorColumn},
+	"full": {
+		domainColumn, ipColumn,
+		column{"Port", "{{.Port}}"},
+		column{"ServerName", "{{.ServerName}}"},
+		issuerColumn, notBeforeColumn, notAfterColumn,
+		column{"CommonName", "{{.CommonName}}"},
+		column{"SANs", `{{join .SANs ", "}}`},
+		column{"SerialNumber", "{{.SerialNumber}}"},
+		column{"SignatureAlgorithm", "{{.SignatureAlgorithm}}"},
+		column{"PublicKeyAlgorithm", "{{.PublicKeyAlgorithm}}"},
+		findingsColumn, errorColumn,
+	},
+	"expiry-only": {domainColumn, notAfterColumn, errorColumn},
+	"security-audit": {
+		domainColumn, issuerColumn,
+		column{"SignatureAlgorithm", "{{.SignatureAlgorithm}}"},
+		column{"PublicKeyAlgorithm", "{{.PublicKeyAlgorithm}}"},
+		column{"Profiles", `{{range $i, $p := .Profiles}}{{if $i}}, {{end}}{{$p.Profile}} {{if $p.Valid}}valid{{else}}invalid{{end}}{{end}}`},
+		findingsColumn, errorColumn,
+	},
+}
+
+var layoutFuncs = map[string]interface{}{"join": strings.Join}
+
+func layoutText(columns []column, format string) string {
+	var b strings.Builder
+	switch format {
+	case "text":
+		width := 0
+		for _, c := range columns {
+			if len(c.name) > width {
+				width = len(c.name)
+			}
+		}
+		b.WriteString("{{range .}}")
+		for _, c := range columns {
+			fmt.Fprintf(&b, "%-*s %s\n", width+1, c.name+":", c.value)
+		}
+		b.WriteString("\n{{end}}")
+	case "md":
+		names := make([]string, len(columns))
+		rule := make([]string, len(columns))
+		values := make([]string, len(columns))
+		for i, c := range columns {
+			names[i], rule[i], values[i] = c.name, "---", c.value
+		}
+		fmt.Fprintf(&b, "%s\n%s\n{{range .}}%s\n{{end}}", strings.Join(names, " | "), strings.Join(rule, " | "), strings.Join(values, " | "))
+	case "html":
+		b.WriteString("<table>\n<tr>")
+		for _, c := range columns {
+			fmt.Fprintf(&b, "<th>%s</th>", c.name)
+		}
+		b.WriteString("</tr>\n{{range .}}<tr>")
+		for _, c := range columns {
+			fmt.Fprintf(&b, "<td>%s</td>", c.value)
+		}
+		b.WriteString("</tr>\n{{end}}</table>\n")
+	}
+	return b.String()
+}
+
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+var layoutTemplates = struct {
+	sync.Mutex
+	m map[string]executor
+}{m: make(map[string]executor)}
+
+func layoutTemplate(layout, format string) (executor, error) {
+	columns, ok := layouts[layout]
+	if !ok {
+		return nil, fmt.Errorf("Unknown layout %q.", layout)
+	}
+	if format != "text" && format != "md" && format != "html" {
+		return nil, fmt.Errorf("Unknown format %q.", format)
+	}
+
+	layoutTemplates.Lock()
+	defer layoutTemplates.Unlock()
+
+	key := layout + "/" + format
+	if t, ok := layoutTemplates.m[key]; ok {
+		return t, nil
+	}
+
+	var t executor
+	var err error
+	if format == "html" {
+		t, err = htmltemplate.New(key).Funcs(layoutFuncs).Parse(layoutText(columns, format))
+	} else {
+		t, err = template.New(key).Funcs(layoutFuncs).Parse(layoutText(columns, format))
+	}
+	if err != nil {
+		return nil, err
+	}
+	layoutTemplates.m[key] = t
+	return t, nil
+}
+
+// Render writes certs in one of the preset layouts summary, full,
+// expiry-only and security-audit, formatted as text, md or html.
+func (certs Certs) Render(w io.Writer, layout, format string) error {
+	t, err := layoutTemplate(layout, format)
+	if err != nil {
+		return err
+	}
+	if format == "md" {
+		return t.Execute(w, certs.escapeStar())
+	}
+	return t.Execute(w, certs)
+}
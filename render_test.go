@@ -0,0 +1,189 @@
+package cert
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	certs := Certs{
+		&Cert{DomainName: "*.example.com", Issuer: "CA for test", NotAfter: "2018-01-01 00:00:00 +0000 UTC"},
+		&Cert{DomainName: "<bad>", Error: "Invalid host name."},
+	}
+
+	var tests = []struct {
+		format   string
+		expected string
+	}{
+		{"text", `DomainName: *.example.com
+Issuer:     CA for test
+NotAfter:   2018-01-01 00:00:00 +0000 UTC
+Error:      
+
+DomainName: <bad>
+Issuer:     
+NotAfter:   
+Error:      Invalid host name.
+
+`},
+		{"md", `DomainName | Issuer | NotAfter | Error
+--- | --- | --- | ---
+*.example.com | CA for test | 2018-01-01 00:00:00 +0000 UTC | 
+<bad> |  |  | Invalid host name.
+`},
+		{"html", `<table>
+<tr><th>DomainName</th><th>Issuer</th><th>NotAfter</th><th>Error</th></tr>
+<tr><td>*.example.com</td><td>CA for test</td><td>2018-01-01 00:00:00 &#43;0000 UTC</td><td></td></tr>
+<tr><td>&lt;bad&gt;</td><td></td><td></td><td>Invalid host name.</td></tr>
+</table>
+`},
+	}
+
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := certs.Render(&b, "summary", test.format); err != nil {
+			t.Fatalf(`%s: unexpected err %s, want nil`, test.format, err.Error())
+		}
+		if b.String() != test.expected {
+			t.Errorf(`%s: unexpected output %q, want %q`, test.format, b.String(), test.expected)
+		}
+	}
+}
+
+func TestRenderLayouts(t *testing.T) {
+	certs := Certs{
+		&Cert{
+			DomainName:         "example.com",
+			IP:                 "192.0.2.1",
+			Port:               "443",
+			ServerName:         "example.com",
+			Issuer:             "CA for test",
+			NotBefore:          "2017-01-01 00:00:00 +0000 UTC",
+			NotAfter:           "2018-01-01 00:00:00 +0000 UTC",
+			CommonName:         "example.com",
+			SANs:               []string{"example.com", "www.example.com"},
+			SerialNumber:       "1",
+			SignatureAlgorithm: "SHA256-RSA",
+			PublicKeyAlgorithm: "RSA",
+			Profiles:           []ProfileResult{{Profile: "modern", Valid: true}, {Profile: "java-8"}},
+			Findings: []Finding{
+				{ID: "expired", Severity: SeverityCritical, Message: "Certificate expired."},
+				{ID: "weak-key", Severity: SeverityWarning, Message: "Key is short."},
+			},
+		},
+		&Cert{DomainName: "example.org", Error: "dial tcp: i/o timeout"},
+	}
+
+	var tests = []struct {
+		layout   string
+		format   string
+		expected string
+	}{
+		{"summary", "text", `DomainName: example.com
+Issuer:     CA for test
+NotAfter:   2018-01-01 00:00:00 +0000 UTC
+Error:      
+
+DomainName: example.org
+Issuer:     
+NotAfter:   
+Error:      dial tcp: i/o timeout
+
+`},
+		{"full", "text", `DomainName:         example.com
+IP:                 192.0.2.1
+Port:               443
+ServerName:         example.com
+Issuer:             CA for test
+NotBefore:          2017-01-01 00:00:00 +0000 UTC
+NotAfter:           2018-01-01 00:00:00 +0000 UTC
+CommonName:         example.com
+SANs:               example.com, www.example.com
+SerialNumber:       1
+SignatureAlgorithm: SHA256-RSA
+PublicKeyAlgorithm: RSA
+Findings:           critical: Certificate expired.; warning: Key is short.
+Error:              
+
+DomainName:         example.org
+IP:                 
+Port:               
+ServerName:         
+Issuer:             
+NotBefore:          
+NotAfter:           
+CommonName:         
+SANs:               
+SerialNumber:       
+SignatureAlgorithm: 
+PublicKeyAlgorithm: 
+Findings:           
+Error:              dial tcp: i/o timeout
+
+`},
+		{"expiry-only", "text", `DomainName: example.com
+NotAfter:   2018-01-01 00:00:00 +0000 UTC
+Error:      
+
+DomainName: example.org
+NotAfter:   
+Error:      dial tcp: i/o timeout
+
+`},
+		{"security-audit", "text", `DomainName:         example.com
+Issuer:             CA for test
+SignatureAlgorithm: SHA256-RSA
+PublicKeyAlgorithm: RSA
+Profiles:           modern valid, java-8 invalid
+Findings:           critical: Certificate expired.; warning: Key is short.
+Error:              
+
+DomainName:         example.org
+Issuer:             
+SignatureAlgorithm: 
+PublicKeyAlgorithm: 
+Profiles:           
+Findings:           
+Error:              dial tcp: i/o timeout
+
+`},
+		{"security-audit", "md", `DomainName | Issuer | SignatureAlgorithm | PublicKeyAlgorithm | Profiles | Findings | Error
+--- | --- | --- | --- | --- | --- | ---
+example.com | CA for test | SHA256-RSA | RSA | modern valid, java-8 invalid | critical: Certificate expired.; warning: Key is short. | 
+example.org |  |  |  |  |  | dial tcp: i/o timeout
+`},
+		{"expiry-only", "html", `<table>
+<tr><th>DomainName</th><th>NotAfter</th><th>Error</th></tr>
+<tr><td>example.com</td><td>2018-01-01 00:00:00 &#43;0000 UTC</td><td></td></tr>
+<tr><td>example.org</td><td></td><td>dial tcp: i/o timeout</td></tr>
+</table>
+`},
+	}
+
+	covered := make(map[string]bool)
+	for _, test := range tests {
+		covered[test.layout] = true
+		var b bytes.Buffer
+		if err := certs.Render(&b, test.layout, test.format); err != nil {
+			t.Fatalf(`%s %s: unexpected err %s, want nil`, test.layout, test.format, err.Error())
+		}
+		if b.String() != test.expected {
+			t.Errorf(`%s %s: unexpected output %q, want %q`, test.layout, test.format, b.String(), test.expected)
+		}
+	}
+	for layout := range layouts {
+		if !covered[layout] {
+			t.Errorf(`layout %s is not tested`, layout)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	var certs Certs
+	if err := certs.Render(&bytes.Buffer{}, "detailed", "text"); err == nil {
+		t.Error(`unexpected nil for an unknown layout, want error`)
+	}
+	if err := certs.Render(&bytes.Buffer{}, "summary", "pdf"); err == nil {
+		t.Error(`unexpected nil for an unknown format, want error`)
+	}
+}
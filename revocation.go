@@ -0,0 +1,275 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+var CheckRevocationEndpoints = false
+
+const maxEndpointResponse = 32 << 20
+
+// endpointCacheTTL is how long the result of an endpoint check is reused
+// for other certificates referencing the same endpoint.
+const endpointCacheTTL = time.Hour
+
+type endpointResult struct {
+	warning string
+	expires time.Time
+	// revoked holds the serial numbers a verified CRL or OCSP response
+	// lists as revoked.
+	revoked map[string]bool
+}
+
+// endpointCheck is shared by concurrent checks of the same endpoint; done
+// is closed once result is set.
+type endpointCheck struct {
+	done   chan struct{}
+	result endpointResult
+}
+
+var endpointResults = struct {
+	sync.Mutex
+	m map[string]*endpointCheck
+}{m: make(map[string]*endpointCheck)}
+
+func readEndpoint(resp *http.Response, url string) ([]byte, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxEndpointResponse))
+}
+
+func fetchEndpoint(url string, timeout time.Duration) ([]byte, error) {
+	resp, err := httpClient(timeout).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	return readEndpoint(resp, url)
+}
+
+// checkOCSPEndpoint asks the responder for the status of leaf and
+// verifies the response was signed for issuer.
+func checkOCSPEndpoint(url string, leaf, issuer *x509.Certificate, timeout time.Duration) endpointResult {
+	r := endpointResult{expires: now().Add(endpointCacheTTL)}
+
+	req, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		r.warning = fmt.Sprintf("OCSP request for %s cannot be created: %v", url, err)
+		return r
+	}
+	resp, err := httpClient(timeout).Post(url, "application/ocsp-request", bytes.NewReader(req))
+	if err != nil {
+		r.warning = fmt.Sprintf("OCSP responder %s is unreachable: %v", url, err)
+		return r
+	}
+	body, err := readEndpoint(resp, url)
+	if err != nil {
+		r.warning = fmt.Sprintf("OCSP responder %s is unavailable: %v", url, err)
+		return r
+	}
+
+	status, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		r.warning = fmt.Sprintf("OCSP responder %s sent an invalid response: %v", url, err)
+		return r
+	}
+	if !status.NextUpdate.IsZero() && now().After(status.NextUpdate) {
+		r.warning = fmt.Sprintf("OCSP response from %s is stale since %s.", url, status.NextUpdate.Format(time.RFC3339))
+		return r
+	}
+
+	switch status.Status {
+	case ocsp.Revoked:
+		r.revoked = map[string]bool{leaf.SerialNumber.String(): true}
+	case ocsp.Unknown:
+		r.warning = fmt.Sprintf("OCSP responder %s does not know the certificate.", url)
+	}
+
+	// A fresh response may go stale before the cache entry would expire.
+	if !status.NextUpdate.IsZero() && status.NextUpdate.Before(r.expires) {
+		r.expires = status.NextUpdate
+	}
+	return r
+}
+
+func checkCRLEndpoint(url string, issuer *x509.Certificate, timeout time.Duration) endpointResult {
+	r := endpointResult{expires: now().Add(endpointCacheTTL)}
+
+	body, err := fetchEndpoint(url, timeout)
+	if err != nil {
+		r.warning = fmt.Sprintf("CRL %s is unavailable: %v", url, err)
+		return r
+	}
+
+	crl, err := x509.ParseRevocationList(body)
+	if err != nil {
+		r.warning = fmt.Sprintf("CRL %s cannot be parsed: %v", url, err)
+		return r
+	}
+	if issuer != nil {
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			r.warning = fmt.Sprintf("CRL %s is not signed by the issuer: %v", url, err)
+			return r
+		}
+		// Entries are only trusted from a CRL the issuer signed.
+		r.revoked = make(map[string]bool, len(crl.RevokedCertificateEntries))
+		for _, e := range crl.RevokedCertificateEntries {
+			r.revoked[e.SerialNumber.String()] = true
+		}
+	}
+	if !crl.NextUpdate.IsZero() && now().After(crl.NextUpdate) {
+		r.warning = fmt.Sprintf("CRL %s is stale since %s.", url, crl.NextUpdate.Format(time.RFC3339))
+		return r
+	}
+
+	// A fresh CRL may go stale before the cache entry would expire.
+	if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(r.expires) {
+		r.expires = crl.NextUpdate
+	}
+	return r
+}
+
+var oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// parseCertsOnly returns the certificates of a "certs-only" PKCS#7
+// SignedData structure, which CA issuers URLs may serve instead of a
+// DER certificate.
+func parseCertsOnly(der []byte) ([]*x509.Certificate, error) {
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	if _, err := asn1.Unmarshal(der, &contentInfo); err != nil {
+		return nil, err
+	}
+	if !contentInfo.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("unexpected PKCS#7 content type %s", contentInfo.ContentType)
+	}
+
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"tag:0,optional"`
+	}
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificates(signedData.Certificates.Bytes)
+}
+
+func checkAIAEndpoint(url string, leaf *x509.Certificate, timeout time.Duration) endpointResult {
+	r := endpointResult{expires: now().Add(endpointCacheTTL)}
+
+	body, err := fetchEndpoint(url, timeout)
+	if err != nil {
+		r.warning = fmt.Sprintf("CA issuers %s is unavailable: %v", url, err)
+		return r
+	}
+
+	issuers, err := x509.ParseCertificates(body)
+	if err != nil {
+		if issuers, err = parseCertsOnly(body); err != nil {
+			r.warning = fmt.Sprintf("CA issuers %s serves neither DER nor PKCS#7 certificates: %v", url, err)
+			return r
+		}
+	}
+	if len(issuers) == 0 {
+		r.warning = fmt.Sprintf("CA issuers %s serves no certificate.", url)
+		return r
+	}
+	for _, issuer := range issuers {
+		if err = leaf.CheckSignatureFrom(issuer); err == nil {
+			return r
+		}
+	}
+	r.warning = fmt.Sprintf("CA issuers %s serves a certificate that did not issue this one: %v", url, err)
+	return r
+}
+
+// cachedEndpointCheck runs check once for concurrent callers with the same
+// key and reuses its result until it expires.
+func cachedEndpointCheck(key string, check func() endpointResult) endpointResult {
+	endpointResults.Lock()
+	c, ok := endpointResults.m[key]
+	if ok {
+		select {
+		case <-c.done:
+			ok = now().Before(c.result.expires)
+		default:
+		}
+	}
+	if !ok {
+		c = &endpointCheck{done: make(chan struct{})}
+		endpointResults.m[key] = c
+	}
+	endpointResults.Unlock()
+
+	if ok {
+		<-c.done
+		return c.result
+	}
+
+	c.result = check()
+	close(c.done)
+	return c.result
+}
+
+// checkRevocationEndpoints returns the warnings about the endpoints the
+// leaf of certChain references and, if one of them lists the leaf as
+// revoked, that endpoint.
+func checkRevocationEndpoints(certChain []*x509.Certificate, timeout time.Duration) ([]string, string) {
+	leaf := certChain[0]
+	var issuer *x509.Certificate
+	if len(certChain) > 1 {
+		issuer = certChain[1]
+	}
+
+	var warnings []string
+	var revokedBy string
+	add := func(url string, r endpointResult) {
+		if r.warning != "" {
+			warnings = append(warnings, r.warning)
+		}
+		if r.revoked[leaf.SerialNumber.String()] {
+			revokedBy = url
+		}
+	}
+
+	// An OCSP request names the issuer, so none is sent without it.
+	if issuer != nil {
+		for _, url := range leaf.OCSPServer {
+			key := "ocsp " + url + " " + spkiHash(issuer) + " " + leaf.SerialNumber.String()
+			add(url, cachedEndpointCheck(key, func() endpointResult {
+				return checkOCSPEndpoint(url, leaf, issuer, timeout)
+			}))
+		}
+	}
+	for _, url := range leaf.CRLDistributionPoints {
+		key := "crl " + url
+		if issuer != nil {
+			key += " " + spkiHash(issuer)
+		}
+		add(url, cachedEndpointCheck(key, func() endpointResult {
+			return checkCRLEndpoint(url, issuer, timeout)
+		}))
+	}
+	for _, url := range leaf.IssuingCertificateURL {
+		add(url, cachedEndpointCheck("aia "+url+" "+string(leaf.RawIssuer), func() endpointResult {
+			return checkAIAEndpoint(url, leaf, timeout)
+		}))
+	}
+	return warnings, revokedBy
+}
@@ -0,0 +1,370 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, cn string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := x509.ParseCertificate(der)
+	return &testCA{c, key}
+}
+
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := x509.ParseCertificate(der)
+	return c
+}
+
+func (ca *testCA) issueCA(t *testing.T, cn string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := x509.ParseCertificate(der)
+	return &testCA{c, key}
+}
+
+func (ca *testCA) crl(t *testing.T, nextUpdate time.Time, revoked ...*big.Int) []byte {
+	var entries []x509.RevocationListEntry
+	for _, serial := range revoked {
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now().Add(-time.Minute)})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now().Add(-time.Hour),
+		NextUpdate:                nextUpdate,
+		RevokedCertificateEntries: entries,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// ocsp answers OCSP requests with status, signed by signer.
+func (ca *testCA) ocsp(t *testing.T, signer *testCA, status int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := ocsp.CreateResponse(ca.cert, signer.cert, ocsp.Response{
+			Status:       status,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Hour),
+			NextUpdate:   time.Now().Add(time.Hour),
+			RevokedAt:    time.Now().Add(-time.Minute),
+		}, signer.key)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write(resp)
+	}
+}
+
+// certsOnly wraps certs in a PKCS#7 SignedData structure without signers.
+func certsOnly(t *testing.T, certs ...*x509.Certificate) []byte {
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.Raw...)
+	}
+	signedData, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms []asn1.RawValue `asn1:"set"`
+		ContentInfo      struct{ ContentType asn1.ObjectIdentifier }
+		Certificates     asn1.RawValue   `asn1:"tag:0"`
+		SignerInfos      []asn1.RawValue `asn1:"set"`
+	}{
+		Version:      1,
+		ContentInfo:  struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: raw},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{oidSignedData, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestCheckRevocationEndpoints(t *testing.T) {
+	ca := newTestCA(t, "CA for test")
+	other := newTestCA(t, "CA for test")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocsp", ca.ocsp(t, ca, ocsp.Good))
+	mux.HandleFunc("/ocsp-unknown", ca.ocsp(t, ca, ocsp.Unknown))
+	mux.HandleFunc("/ocsp-forged", ca.ocsp(t, other, ocsp.Good))
+	mux.HandleFunc("/ocsp-garbage", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("garbage"))
+	})
+	mux.HandleFunc("/ocsp-broken", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "oops", http.StatusBadGateway)
+	})
+	mux.HandleFunc("/ca.crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ca.crl(t, time.Now().Add(time.Hour)))
+	})
+	mux.HandleFunc("/stale.crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ca.crl(t, time.Now().Add(-time.Minute)))
+	})
+	mux.HandleFunc("/other.crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(other.crl(t, time.Now().Add(time.Hour)))
+	})
+	mux.HandleFunc("/ca.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ca.cert.Raw)
+	})
+	mux.HandleFunc("/other.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(other.cert.Raw)
+	})
+	mux.HandleFunc("/ca.p7c", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(certsOnly(t, other.cert, ca.cert))
+	})
+	mux.HandleFunc("/other.p7c", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(certsOnly(t, other.cert))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	defer func() { endpointResults.m = make(map[string]*endpointCheck) }()
+
+	var tests = []struct {
+		ocsp string
+		crl  string
+		aia  string
+		want string
+	}{
+		{"/ocsp", "/ca.crl", "/ca.crt", ""},
+		{"/ocsp", "/ca.crl", "/ca.p7c", ""},
+		{"/ocsp-broken", "/ca.crl", "/ca.crt", "OCSP responder " + ts.URL + "/ocsp-broken is unavailable: " + ts.URL + "/ocsp-broken returned 502 Bad Gateway"},
+		{"/ocsp-unknown", "/ca.crl", "/ca.crt", "OCSP responder " + ts.URL + "/ocsp-unknown does not know the certificate."},
+		{"/ocsp-forged", "/ca.crl", "/ca.crt", "OCSP responder " + ts.URL + "/ocsp-forged sent an invalid response"},
+		{"/ocsp-garbage", "/ca.crl", "/ca.crt", "OCSP responder " + ts.URL + "/ocsp-garbage sent an invalid response"},
+		{"/ocsp", "/stale.crl", "/ca.crt", "CRL " + ts.URL + "/stale.crl is stale since"},
+		{"/ocsp", "/other.crl", "/ca.crt", "CRL " + ts.URL + "/other.crl is not signed by the issuer"},
+		{"/ocsp", "/missing.crl", "/ca.crt", "CRL " + ts.URL + "/missing.crl is unavailable"},
+		{"/ocsp", "/ca.crl", "/other.crt", "CA issuers " + ts.URL + "/other.crt serves a certificate that did not issue this one"},
+		{"/ocsp", "/ca.crl", "/other.p7c", "CA issuers " + ts.URL + "/other.p7c serves a certificate that did not issue this one"},
+		{"/ocsp", "/ca.crl", "/missing.crt", "CA issuers " + ts.URL + "/missing.crt is unavailable"},
+	}
+
+	for i, test := range tests {
+		leaf := ca.issue(t, &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 2)),
+			Subject:               pkix.Name{CommonName: "example.com"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			OCSPServer:            []string{ts.URL + test.ocsp},
+			CRLDistributionPoints: []string{ts.URL + test.crl},
+			IssuingCertificateURL: []string{ts.URL + test.aia},
+		})
+
+		warnings, _ := checkRevocationEndpoints([]*x509.Certificate{leaf, ca.cert}, time.Second)
+
+		if test.want == "" {
+			if len(warnings) != 0 {
+				t.Errorf(`%d: unexpected warnings %v, want none`, i, warnings)
+			}
+			continue
+		}
+		if len(warnings) != 1 || !strings.HasPrefix(warnings[0], test.want) {
+			t.Errorf(`%d: unexpected warnings %v, want %q`, i, warnings, test.want)
+		}
+	}
+}
+
+func TestRevocationEndpointCacheExpires(t *testing.T) {
+	ca := newTestCA(t, "CA for test")
+	nextUpdate := time.Now().Add(time.Minute)
+	crl := ca.crl(t, nextUpdate)
+
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(crl)
+	}))
+	defer ts.Close()
+	defer func() {
+		endpointResults.m = make(map[string]*endpointCheck)
+		now = time.Now
+	}()
+
+	leaf := ca.issue(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		CRLDistributionPoints: []string{ts.URL + "/ca.crl"},
+	})
+	chain := []*x509.Certificate{leaf, ca.cert}
+
+	for i := 0; i < 2; i++ {
+		if warnings, _ := checkRevocationEndpoints(chain, time.Second); len(warnings) != 0 {
+			t.Errorf(`unexpected warnings %v, want none`, warnings)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf(`unexpected %d fetches, want the fresh CRL cached`, fetches)
+	}
+
+	now = func() time.Time { return nextUpdate.Add(time.Second) }
+
+	warnings, _ := checkRevocationEndpoints(chain, time.Second)
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "CRL "+ts.URL+"/ca.crl is stale since") {
+		t.Errorf(`unexpected warnings %v, want the CRL reported stale`, warnings)
+	}
+}
+
+func TestRevokedLeaf(t *testing.T) {
+	ca := newTestCA(t, "CA for test")
+	other := newTestCA(t, "CA for test")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ca.crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ca.crl(t, time.Now().Add(time.Hour), big.NewInt(2)))
+	})
+	mux.HandleFunc("/forged.crl", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(other.crl(t, time.Now().Add(time.Hour), big.NewInt(2), big.NewInt(3)))
+	})
+	mux.HandleFunc("/ocsp-revoked", ca.ocsp(t, ca, ocsp.Revoked))
+	mux.HandleFunc("/ocsp-forged", ca.ocsp(t, other, ocsp.Revoked))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	defer func() { endpointResults.m = make(map[string]*endpointCheck) }()
+
+	var tests = []struct {
+		serial int64
+		ocsp   string
+		crl    string
+		want   string
+	}{
+		{2, "", "/ca.crl", ts.URL + "/ca.crl"},
+		{3, "", "/ca.crl", ""},
+		{3, "", "/forged.crl", ""},
+		{4, "/ocsp-revoked", "/ca.crl", ts.URL + "/ocsp-revoked"},
+		{4, "/ocsp-forged", "/ca.crl", ""},
+	}
+
+	for i, test := range tests {
+		leaf := ca.issue(t, &x509.Certificate{
+			SerialNumber:          big.NewInt(test.serial),
+			Subject:               pkix.Name{CommonName: "example.com"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			CRLDistributionPoints: []string{ts.URL + test.crl},
+		})
+		if test.ocsp != "" {
+			leaf.OCSPServer = []string{ts.URL + test.ocsp}
+		}
+
+		if _, revokedBy := checkRevocationEndpoints([]*x509.Certificate{leaf, ca.cert}, time.Second); revokedBy != test.want {
+			t.Errorf(`%d: unexpected revoking endpoint %q, want %q`, i, revokedBy, test.want)
+		}
+	}
+}
+
+func TestRevocationEndpointSingleFlight(t *testing.T) {
+	ca := newTestCA(t, "CA for test")
+	crl := ca.crl(t, time.Now().Add(time.Hour))
+
+	var mu sync.Mutex
+	fetches := 0
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		<-release
+		w.Write(crl)
+	}))
+	defer ts.Close()
+	defer func() { endpointResults.m = make(map[string]*endpointCheck) }()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		leaf := ca.issue(t, &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 2)),
+			Subject:               pkix.Name{CommonName: "example.com"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			CRLDistributionPoints: []string{ts.URL + "/ca.crl"},
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if warnings, _ := checkRevocationEndpoints([]*x509.Certificate{leaf, ca.cert}, 5*time.Second); len(warnings) != 0 {
+				t.Errorf(`unexpected warnings %v, want none`, warnings)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches != 1 {
+		t.Errorf(`unexpected %d fetches, want concurrent checks to share one`, fetches)
+	}
+}
@@ -0,0 +1,110 @@
+package cert
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+)
+
+// reported returns the findings of c together with a failed check, as the
+// outputs that report findings show both.
+func (c *Cert) reported() []Finding {
+	if c.Error == "" {
+		return c.Findings
+	}
+	return append([]Finding{{ID: "scan-error", Severity: SeverityWarning, Message: c.Error}}, c.Findings...)
+}
+
+func (c *Cert) hostPort() string {
+	if c.Port == "" {
+		return c.DomainName
+	}
+	return net.JoinHostPort(c.DomainName, c.Port)
+}
+
+func (f Finding) text() string {
+	if f.Remediation == "" {
+		return f.Message
+	}
+	return f.Message + " Remediation: " + f.Remediation
+}
+
+var sarifLevels = map[Severity]string{
+	SeverityInfo:     "note",
+	SeverityWarning:  "warning",
+	SeverityCritical: "error",
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver sarifDriver `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// WriteSARIF writes the findings and failed checks of certs as a SARIF
+// 2.1.0 log, located by host and port, for code scanning dashboards.
+func (certs Certs) WriteSARIF(w io.Writer) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver = sarifDriver{Name: "cert", InformationURI: "https://github.com/genkiroid/cert", Rules: []sarifRule{}}
+
+	rules := make(map[string]bool)
+	for _, c := range certs {
+		for _, f := range c.reported() {
+			if !rules[f.ID] {
+				rules[f.ID] = true
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: f.ID})
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    f.ID,
+				Level:     sarifLevels[f.Severity],
+				Message:   sarifMessage{Text: f.text()},
+				Locations: []sarifLocation{{LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: c.hostPort(), Kind: "resource"}}}},
+			})
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
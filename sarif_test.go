@@ -0,0 +1,119 @@
+package cert
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	certs := Certs{
+		&Cert{
+			DomainName: "example.com",
+			Port:       "443",
+			Findings: []Finding{
+				{ID: "expired", Severity: SeverityCritical, Message: "Certificate expired.", Remediation: "renew the certificate"},
+				{ID: "weak-key", Severity: SeverityInfo, Message: "Key is short."},
+			},
+		},
+		&Cert{DomainName: "example.org", Error: "dial tcp: i/o timeout"},
+		&Cert{DomainName: "example.net", Port: "443"},
+	}
+
+	expected := `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "cert",
+          "informationUri": "https://github.com/genkiroid/cert",
+          "rules": [
+            {
+              "id": "expired"
+            },
+            {
+              "id": "weak-key"
+            },
+            {
+              "id": "scan-error"
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "expired",
+          "level": "error",
+          "message": {
+            "text": "Certificate expired. Remediation: renew the certificate"
+          },
+          "locations": [
+            {
+              "logicalLocations": [
+                {
+                  "fullyQualifiedName": "example.com:443",
+                  "kind": "resource"
+                }
+              ]
+            }
+          ]
+        },
+        {
+          "ruleId": "weak-key",
+          "level": "note",
+          "message": {
+            "text": "Key is short."
+          },
+          "locations": [
+            {
+              "logicalLocations": [
+                {
+                  "fullyQualifiedName": "example.com:443",
+                  "kind": "resource"
+                }
+              ]
+            }
+          ]
+        },
+        {
+          "ruleId": "scan-error",
+          "level": "warning",
+          "message": {
+            "text": "dial tcp: i/o timeout"
+          },
+          "locations": [
+            {
+              "logicalLocations": [
+                {
+                  "fullyQualifiedName": "example.org",
+                  "kind": "resource"
+                }
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}
+`
+
+	var b bytes.Buffer
+	if err := certs.WriteSARIF(&b); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if b.String() != expected {
+		t.Errorf(`unexpected output %s, want %s`, b.String(), expected)
+	}
+}
+
+func TestWriteSARIFWithoutFindings(t *testing.T) {
+	var b bytes.Buffer
+	if err := (Certs{&Cert{DomainName: "example.net"}}).WriteSARIF(&b); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if !bytes.Contains(b.Bytes(), []byte(`"results": []`)) || !bytes.Contains(b.Bytes(), []byte(`"rules": []`)) {
+		t.Errorf(`unexpected output %s, want empty rules and results`, b.String())
+	}
+}
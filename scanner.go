@@ -0,0 +1,118 @@
+package cert
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+const defaultTimeout = 3 * time.Second
+
+// Sink receives the raw chain of every successful check of a Scanner.
+// Put is called concurrently. Close flushes what was put and is called
+// once by Scanner.Shutdown after the checks in flight have finished.
+type Sink interface {
+	Put(c *Cert, der [][]byte) error
+	Close() error
+}
+
+// SinkFunc adapts a function to a Sink that needs no flushing.
+type SinkFunc func(c *Cert, der [][]byte)
+
+func (f SinkFunc) Put(c *Cert, der [][]byte) error {
+	f(c, der)
+	return nil
+}
+
+func (f SinkFunc) Close() error {
+	return nil
+}
+
+// Scanner checks certificates with its own options, independent of the
+// package-level variables, and can be shut down on its own.
+type Scanner struct {
+	SkipVerify bool
+	UTC        bool
+	// Timeout is how long to wait for a server or lookup. Zero means the
+	// default of 3 seconds.
+	Timeout time.Duration
+	Sink    Sink
+	// Strict refuses to check while package-level variables that have
+	// a Scanner option are set, to find code still using them.
+	Strict bool
+
+	// legacy makes the Scanner read the package-level variables.
+	legacy bool
+
+	mu         sync.Mutex
+	closed     bool
+	inFlight   int
+	drained    chan struct{}
+	done       chan struct{}
+	sinkClosed bool
+}
+
+func NewScanner() *Scanner {
+	return &Scanner{Timeout: defaultTimeout}
+}
+
+// defaultScanner serves the package-level functions.
+var defaultScanner = &Scanner{legacy: true}
+
+func (sc *Scanner) skipVerify() bool {
+	if sc.legacy {
+		return SkipVerify
+	}
+	return sc.SkipVerify
+}
+
+func (sc *Scanner) location() *time.Location {
+	if (sc.legacy && UTC) || (!sc.legacy && sc.UTC) {
+		return time.UTC
+	}
+	return time.Local
+}
+
+func (sc *Scanner) timeout() time.Duration {
+	if sc.legacy {
+		return time.Duration(TimeoutSeconds) * time.Second
+	}
+	if sc.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return sc.Timeout
+}
+
+func (sc *Scanner) sink() Sink {
+	if sc.legacy && DERSink != nil {
+		return SinkFunc(DERSink)
+	}
+	return sc.Sink
+}
+
+func (sc *Scanner) NewCert(hostport string) *Cert {
+	if err := sc.checkStrict(); err != nil {
+		return &Cert{DomainName: hostport, Error: err.Error()}
+	}
+	t, err := parseTarget(hostport)
+	if err != nil {
+		return &Cert{DomainName: t.serverName, Error: err.Error()}
+	}
+	return sc.checkTarget(t)
+}
+
+func NewCert(hostport string) *Cert {
+	return defaultScanner.NewCert(hostport)
+}
+
+func NewCerts(s []string) (Certs, error) {
+	return defaultScanner.NewCerts(s)
+}
+
+func StreamJSON(w io.Writer, s []string) error {
+	return defaultScanner.StreamJSON(w, s)
+}
+
+func NewCertsForSNIs(hostport string, sniNames []string) (Certs, error) {
+	return defaultScanner.NewCertsForSNIs(hostport, sniNames)
+}
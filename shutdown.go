@@ -0,0 +1,98 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+)
+
+var errShutdown = fmt.Errorf("Checks are shut down.")
+
+func (sc *Scanner) beginCheck() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed {
+		return false
+	}
+	sc.inFlight++
+	return true
+}
+
+func (sc *Scanner) endCheck() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.inFlight--
+	if sc.inFlight == 0 && sc.drained != nil {
+		close(sc.drained)
+		sc.drained = nil
+	}
+}
+
+// stopped is closed once the Scanner is shut down, to cut short checks
+// waiting for their turn.
+func (sc *Scanner) stopped() <-chan struct{} {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.done == nil {
+		sc.done = make(chan struct{})
+	}
+	return sc.done
+}
+
+// Shutdown stops the Scanner from starting checks, waits for those in
+// flight to finish and then closes its Sink. Checks waiting for their
+// turn are reported as shut down. A Scanner cannot be restarted; create
+// a new one instead.
+func (sc *Scanner) Shutdown(ctx context.Context) error {
+	sc.mu.Lock()
+	if !sc.closed {
+		sc.closed = true
+		if sc.done == nil {
+			sc.done = make(chan struct{})
+		}
+		close(sc.done)
+	}
+	var drained chan struct{}
+	if sc.inFlight > 0 {
+		if sc.drained == nil {
+			sc.drained = make(chan struct{})
+		}
+		drained = sc.drained
+	}
+	sc.mu.Unlock()
+
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return sc.closeSink()
+}
+
+// closeSink closes the Sink on the first call only.
+func (sc *Scanner) closeSink() error {
+	sc.mu.Lock()
+	sink := sc.sink()
+	closed := sc.sinkClosed
+	sc.sinkClosed = true
+	sc.mu.Unlock()
+
+	if sink == nil || closed {
+		return nil
+	}
+	return sink.Close()
+}
+
+func (sc *Scanner) Close() error {
+	return sc.Shutdown(context.Background())
+}
+
+// Shutdown shuts down the checks of the package-level functions for the
+// rest of the process. Use a Scanner for checks that can be restarted.
+func Shutdown(ctx context.Context) error {
+	return defaultScanner.Shutdown(ctx)
+}
@@ -0,0 +1,201 @@
+package cert
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func reopenChecks() {
+	defaultScanner.mu.Lock()
+	defaultScanner.closed = false
+	defaultScanner.done = nil
+	defaultScanner.sinkClosed = false
+	defaultScanner.mu.Unlock()
+}
+
+func TestShutdownDrainsInFlightChecks(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		started <- struct{}{}
+		<-release
+		return []*x509.Certificate{&x509.Certificate{}}, "127.0.0.1", nil
+	}
+	defer func() {
+		stubCert()
+		reopenChecks()
+	}()
+
+	result := make(chan *Cert)
+	go func() { result <- NewCert("example.com") }()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf(`unexpected err %v, want %v`, err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if c := <-result; c.Error != "" {
+		t.Errorf(`in-flight check was not completed, got Cert.Error %q`, c.Error)
+	}
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf(`unexpected err %s, want nil`, err.Error())
+	}
+}
+
+func TestNewCertsAfterShutdown(t *testing.T) {
+	defer reopenChecks()
+
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	if _, err := NewCerts([]string{"example.com"}); err != errShutdown {
+		t.Errorf(`unexpected err %v, want %v`, err, errShutdown)
+	}
+	if c := NewCert("example.com"); c.Error != errShutdown.Error() {
+		t.Errorf(`unexpected Cert.Error %q, want %q`, c.Error, errShutdown.Error())
+	}
+}
+
+func TestShutdownWaitsForCheckpoint(t *testing.T) {
+	Checkpoint = tempCheckpoint(t, "")
+	defer func() {
+		os.Remove(Checkpoint)
+		Checkpoint = ""
+	}()
+
+	fetched := make(chan string, 2)
+	release := make(chan struct{})
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		fetched <- tg.host
+		if tg.host == "example.com" {
+			<-release
+		}
+		return []*x509.Certificate{&x509.Certificate{}}, "127.0.0.1", nil
+	}
+	defer stubCert()
+
+	sc := NewScanner()
+	result := make(chan error, 1)
+	go func() {
+		_, err := sc.NewCerts([]string{"example.com", "example.org"})
+		result <- err
+	}()
+	<-fetched
+	<-fetched
+
+	shutdown := make(chan error)
+	go func() { shutdown <- sc.Shutdown(context.Background()) }()
+	<-sc.stopped()
+
+	// The check of example.com holds the scan until it is released.
+	select {
+	case err := <-shutdown:
+		t.Fatalf(`Shutdown returned %v while a check was in flight`, err)
+	default:
+	}
+
+	close(release)
+	if err := <-shutdown; err != nil {
+		t.Errorf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	// The scan must have recorded its results by the time Shutdown returns.
+	if _, err := os.Stat(Checkpoint); !os.IsNotExist(err) {
+		t.Errorf(`unexpected checkpoint left after a complete scan: %v`, err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf(`unexpected err %s, want nil`, err.Error())
+	}
+}
+
+func TestShutdownInterruptsPacing(t *testing.T) {
+	Budget = time.Minute
+	defer func() { Budget = 0 }()
+
+	fetched := make(chan struct{}, 3)
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		fetched <- struct{}{}
+		return []*x509.Certificate{&x509.Certificate{}}, "127.0.0.1", nil
+	}
+	defer stubCert()
+
+	sc := NewScanner()
+	sc.Timeout = time.Second
+	result := make(chan Certs, 1)
+	go func() {
+		certs, _ := sc.NewCerts([]string{"example.com", "example.org", "example.net"})
+		result <- certs
+	}()
+
+	// The first check starts at once, the others are spread over the
+	// budget.
+	<-fetched
+	start := time.Now()
+	if err := sc.Shutdown(context.Background()); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf(`Shutdown took %s, want it to cut waiting checks short`, elapsed)
+	}
+
+	shutdown := 0
+	for _, c := range <-result {
+		if c.Error == errShutdown.Error() {
+			shutdown++
+		}
+	}
+	if shutdown != 2 {
+		t.Errorf(`unexpected %d checks shut down, want %d`, shutdown, 2)
+	}
+}
+
+type recordingSink struct {
+	puts   int
+	closed int
+}
+
+func (s *recordingSink) Put(c *Cert, der [][]byte) error {
+	s.puts++
+	return nil
+}
+
+func (s *recordingSink) Close() error {
+	s.closed++
+	return errors.New("flush failed")
+}
+
+func TestScannerShutdownClosesSink(t *testing.T) {
+	sink := &recordingSink{}
+	sc := NewScanner()
+	sc.Sink = sink
+
+	if c := sc.NewCert("example.com"); c.Error != "" {
+		t.Fatalf(`unexpected Cert.Error %q`, c.Error)
+	}
+	if err := sc.Close(); err == nil || err.Error() != "flush failed" {
+		t.Errorf(`unexpected err %v, want the error of Sink.Close`, err)
+	}
+	if err := sc.Close(); err != nil {
+		t.Errorf(`unexpected err %s, want nil`, err.Error())
+	}
+	if sink.puts != 1 || sink.closed != 1 {
+		t.Errorf(`unexpected %d puts and %d closes, want 1 and 1`, sink.puts, sink.closed)
+	}
+
+	// Other scanners and the package-level functions keep working.
+	if c := NewScanner().NewCert("example.com"); c.Error != "" {
+		t.Errorf(`unexpected Cert.Error %q from a new Scanner`, c.Error)
+	}
+	if c := NewCert("example.com"); c.Error != "" {
+		t.Errorf(`unexpected Cert.Error %q from NewCert`, c.Error)
+	}
+}
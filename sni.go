@@ -0,0 +1,95 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// resolveIP replaces the host of t by one of its addresses, so that
+// several checks of it reach the same server.
+func resolveIP(t target, timeout time.Duration) (target, error) {
+	if net.ParseIP(t.host) != nil {
+		return t, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	addrs, err := resolver().LookupIPAddr(ctx, t.host)
+	if err != nil {
+		return t, err
+	}
+	for _, a := range addrs {
+		if Network == "tcp" || (Network == "tcp4") == (a.IP.To4() != nil) {
+			t.host = a.IP.String()
+			return t, nil
+		}
+	}
+	return t, fmt.Errorf("No %s address was found for %s.", Network, t.host)
+}
+
+func (sc *Scanner) NewCertsForSNIs(hostport string, sniNames []string) (Certs, error) {
+	if err := sc.checkStrict(); err != nil {
+		return nil, err
+	}
+	t, err := parseTarget(hostport)
+	if err != nil {
+		return nil, err
+	}
+	if len(sniNames) < 1 {
+		return nil, fmt.Errorf("Input at least one SNI name.")
+	}
+
+	var invalid ValidationError
+	for i, sni := range sniNames {
+		if err := checkHostName(sni); err != nil {
+			invalid = append(invalid, InvalidTarget{Index: i, Target: sni, Err: err})
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+	if !sc.beginCheck() {
+		return nil, errShutdown
+	}
+	defer sc.endCheck()
+
+	t.scanner = sc
+	t = resolveService(t)
+	t.explicitPort = true
+	t, err = resolveIP(t, sc.timeout())
+
+	certs := make(Certs, len(sniNames))
+	if err != nil {
+		for i, sni := range sniNames {
+			certs[i] = &Cert{DomainName: sni, Host: t.host, Port: t.port, ServerName: sni, Error: err.Error()}
+		}
+		return certs, nil
+	}
+
+	type indexer struct {
+		index int
+		cert  *Cert
+	}
+
+	targets := make([]target, len(sniNames))
+	for i, sni := range sniNames {
+		targets[i] = t
+		targets[i].serverName = sni
+	}
+
+	l := newLimiter(sc, targets)
+	ch := make(chan *indexer)
+	for _, i := range checkOrder(len(sniNames)) {
+		go func(i int) {
+			ch <- &indexer{i, l.checkTarget(targets[i])}
+		}(i)
+	}
+
+	for range sniNames {
+		i := <-ch
+		certs[i.index] = i.cert
+	}
+	return certs, nil
+}
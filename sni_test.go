@@ -0,0 +1,67 @@
+package cert
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewCertsForSNIs(t *testing.T) {
+	var mu sync.Mutex
+	dialed := make(map[string]bool)
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		mu.Lock()
+		dialed[tg.host+":"+tg.port] = true
+		mu.Unlock()
+		return []*x509.Certificate{{Subject: pkix.Name{CommonName: tg.serverName}}}, tg.host, nil
+	}
+	defer stubCert()
+
+	certs, err := NewCertsForSNIs("192.0.2.1:8443", []string{"a.example.com", "b.example.com"})
+	if err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	for i, sni := range []string{"a.example.com", "b.example.com"} {
+		c := certs[i]
+		if c.DomainName != sni || c.ServerName != sni || c.CommonName != sni || c.IP != "192.0.2.1" {
+			t.Errorf(`unexpected Cert %+v for SNI %q`, c, sni)
+		}
+	}
+	if len(dialed) != 1 || !dialed["192.0.2.1:8443"] {
+		t.Errorf(`unexpected dialed addresses %v, want only 192.0.2.1:8443`, dialed)
+	}
+}
+
+func TestNewCertsForSNIsError(t *testing.T) {
+	var tests = []struct {
+		target string
+		snis   []string
+	}{
+		{"example.com:nosuchservice", []string{"a.example.com"}},
+		{"example.com", nil},
+		{"example.com", []string{"a.example.com", "bad name"}},
+	}
+
+	for _, test := range tests {
+		if _, err := NewCertsForSNIs(test.target, test.snis); err == nil {
+			t.Errorf(`NewCertsForSNIs(%q, %q): unexpected nil, want error`, test.target, test.snis)
+		}
+	}
+}
+
+func TestResolveIP(t *testing.T) {
+	defer func() { Network = "tcp" }()
+
+	tg, err := resolveIP(target{host: "localhost"}, time.Second)
+	if err != nil || (tg.host != "127.0.0.1" && tg.host != "::1") {
+		t.Errorf(`unexpected host %q and err %v, want a loopback address`, tg.host, err)
+	}
+
+	Network = "tcp4"
+	if tg, err := resolveIP(target{host: "localhost"}, time.Second); err != nil || tg.host != "127.0.0.1" {
+		t.Errorf(`unexpected host %q and err %v, want 127.0.0.1`, tg.host, err)
+	}
+}
@@ -0,0 +1,99 @@
+package cert
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// StreamBuffer is how many results StreamJSON holds while w is busy.
+// Zero makes checks wait for w.
+var StreamBuffer = 64
+
+func (sc *Scanner) StreamJSON(w io.Writer, s []string) error {
+	if err := sc.checkStrict(); err != nil {
+		return err
+	}
+	if err := validate(s); err != nil {
+		return err
+	}
+	if !sc.beginCheck() {
+		return errShutdown
+	}
+	defer sc.endCheck()
+
+	s = sc.prepareTargets(s)
+
+	cp, err := openCheckpoint(Checkpoint)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	var pending []string
+	for _, d := range s {
+		c, ok := cp.lookup(d)
+		if !ok {
+			pending = append(pending, d)
+			continue
+		}
+		if err := enc.Encode(c); err != nil {
+			cp.finish(false)
+			return err
+		}
+	}
+	s = make([]string, len(pending))
+	for i, j := range checkOrder(len(pending)) {
+		s[i] = pending[j]
+	}
+
+	targets := make(chan string)
+	results := make(chan *Cert, StreamBuffer)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(targets)
+		for _, d := range s {
+			select {
+			case targets <- d:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	l := newLimiter(sc, parseTargets(s))
+	var wg sync.WaitGroup
+	for i := 0; i < cap(tokens) && i < len(s); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range targets {
+				c := l.check(d)
+				cp.record(d, c)
+
+				select {
+				case results <- c:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for c := range results {
+		if err := enc.Encode(c); err != nil {
+			close(done)
+			for range results {
+			}
+			cp.finish(false)
+			return err
+		}
+	}
+	return cp.finish(true)
+}
@@ -0,0 +1,102 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamJSON(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := StreamJSON(&b, []string{"example.com", "example.org", "example.net"}); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf(`unexpected number of lines %d, want %d`, len(lines), 3)
+	}
+
+	var got []string
+	for _, line := range lines {
+		var c Cert
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			t.Fatalf(`unexpected err %s for line %q`, err.Error(), line)
+		}
+		got = append(got, c.DomainName)
+	}
+	sort.Strings(got)
+
+	if strings.Join(got, ",") != "example.com,example.net,example.org" {
+		t.Errorf(`unexpected domain names %v`, got)
+	}
+}
+
+func TestStreamJSONError(t *testing.T) {
+	if err := StreamJSON(&bytes.Buffer{}, []string{}); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
+
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return 0, errors.New("sink closed")
+}
+
+func TestStreamJSONBackPressure(t *testing.T) {
+	var dials int32
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		atomic.AddInt32(&dials, 1)
+		return []*x509.Certificate{&x509.Certificate{}}, "127.0.0.1", nil
+	}
+	StreamBuffer = 1
+	defer func() {
+		stubCert()
+		StreamBuffer = 64
+	}()
+
+	s := make([]string, 1000)
+	for i := range s {
+		s[i] = fmt.Sprintf("host%d.example.com", i)
+	}
+
+	w := &blockingWriter{release: make(chan struct{})}
+	errc := make(chan error)
+	go func() { errc <- StreamJSON(w, s) }()
+
+	time.Sleep(50 * time.Millisecond)
+	max := int32(cap(tokens) + StreamBuffer + 1)
+	if n := atomic.LoadInt32(&dials); n > max {
+		t.Errorf(`unexpected dials %d while sink is blocked, want at most %d`, n, max)
+	}
+
+	close(w.release)
+	if err := <-errc; err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
+
+func TestStreamJSONInvalidBuffer(t *testing.T) {
+	StreamBuffer = -1
+	defer func() { StreamBuffer = 64 }()
+
+	var b bytes.Buffer
+	if err := StreamJSON(&b, []string{"example.com"}); err == nil || err.Error() != "Invalid stream buffer -1. Use 0 or more." {
+		t.Errorf(`unexpected err %v, want the buffer refused`, err)
+	}
+	if _, err := NewCerts([]string{"example.com"}); err == nil {
+		t.Error(`unexpected nil from NewCerts, want error`)
+	}
+}
@@ -0,0 +1,39 @@
+package cert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// legacySettings are the package-level variables a Scanner has its own
+// options for.
+var legacySettings = []struct {
+	name        string
+	replacement string
+	set         func() bool
+}{
+	{"SkipVerify", "Scanner.SkipVerify", func() bool { return SkipVerify }},
+	{"UTC", "Scanner.UTC", func() bool { return UTC }},
+	{"TimeoutSeconds", "Scanner.Timeout", func() bool { return TimeoutSeconds != int(defaultTimeout/time.Second) }},
+	{"DERSink", "Scanner.Sink", func() bool { return DERSink != nil }},
+}
+
+// checkStrict returns an error naming every legacy setting in use if the
+// Scanner is strict.
+func (sc *Scanner) checkStrict() error {
+	if !sc.Strict {
+		return nil
+	}
+
+	var set []string
+	for _, l := range legacySettings {
+		if l.set() {
+			set = append(set, fmt.Sprintf("cert.%s is set, use %s instead", l.name, l.replacement))
+		}
+	}
+	if len(set) > 0 {
+		return fmt.Errorf("Strict mode refuses legacy settings: %s.", strings.Join(set, "; "))
+	}
+	return nil
+}
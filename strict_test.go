@@ -0,0 +1,72 @@
+package cert
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStrict(t *testing.T) {
+	utc := UTC
+	UTC = false
+	defer func() { UTC = utc }()
+
+	sc := NewScanner()
+	sc.Strict = true
+
+	if c := sc.NewCert("example.com"); c.Error != "" {
+		t.Fatalf(`unexpected Cert.Error %q without legacy settings`, c.Error)
+	}
+
+	SkipVerify = true
+	TimeoutSeconds = 10
+	defer func() {
+		SkipVerify = false
+		TimeoutSeconds = 3
+	}()
+
+	want := "Strict mode refuses legacy settings: cert.SkipVerify is set, use Scanner.SkipVerify instead; cert.TimeoutSeconds is set, use Scanner.Timeout instead."
+	if c := sc.NewCert("example.com"); c.Error != want {
+		t.Errorf(`unexpected Cert.Error %q, want %q`, c.Error, want)
+	}
+	if _, err := sc.NewCerts([]string{"example.com"}); err == nil || err.Error() != want {
+		t.Errorf(`unexpected err %v, want %q`, err, want)
+	}
+	var b strings.Builder
+	if err := sc.StreamJSON(&b, []string{"example.com"}); err == nil || err.Error() != want {
+		t.Errorf(`unexpected err %v, want %q`, err, want)
+	}
+	if _, err := sc.NewCertsForSNIs("example.com", []string{"example.com"}); err == nil || err.Error() != want {
+		t.Errorf(`unexpected err %v, want %q`, err, want)
+	}
+
+	// Only strict scanners refuse them.
+	loose := NewScanner()
+	loose.Timeout = time.Second
+	if c := loose.NewCert("example.com"); c.Error != "" {
+		t.Errorf(`unexpected Cert.Error %q from a Scanner that is not strict`, c.Error)
+	}
+}
+
+func TestStrictReportsEverySetting(t *testing.T) {
+	utc := UTC
+	UTC = true
+	DERSink = func(c *Cert, der [][]byte) {}
+	defer func() {
+		UTC = utc
+		DERSink = nil
+	}()
+
+	sc := NewScanner()
+	sc.Strict = true
+
+	err := sc.checkStrict()
+	if err == nil {
+		t.Fatal(`unexpected nil, want error`)
+	}
+	for _, replacement := range []string{"Scanner.UTC", "Scanner.Sink"} {
+		if !strings.Contains(err.Error(), replacement) {
+			t.Errorf(`unexpected err %q, want it to name %s`, err.Error(), replacement)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cert
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+var UnreachableBackoff time.Duration
+
+var MaxUnreachableBackoff = time.Hour
+
+type unreachable struct {
+	failures int
+	until    time.Time
+}
+
+var unreachables = struct {
+	sync.Mutex
+	m map[string]*unreachable
+}{m: make(map[string]*unreachable)}
+
+var now = time.Now
+
+func isUnreachable(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func unreachableUntil(hostport string) (time.Time, bool) {
+	if UnreachableBackoff <= 0 {
+		return time.Time{}, false
+	}
+
+	unreachables.Lock()
+	defer unreachables.Unlock()
+
+	u, ok := unreachables.m[hostport]
+	if !ok || !now().Before(u.until) {
+		return time.Time{}, false
+	}
+	return u.until, true
+}
+
+func recordReachability(hostport string, err error) {
+	if UnreachableBackoff <= 0 {
+		return
+	}
+
+	unreachables.Lock()
+	defer unreachables.Unlock()
+
+	if err == nil || !isUnreachable(err) {
+		delete(unreachables.m, hostport)
+		return
+	}
+
+	u, ok := unreachables.m[hostport]
+	if !ok {
+		u = &unreachable{}
+		unreachables.m[hostport] = u
+	}
+	u.failures++
+
+	backoff := UnreachableBackoff
+	for i := 1; i < u.failures && backoff < MaxUnreachableBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > MaxUnreachableBackoff {
+		backoff = MaxUnreachableBackoff
+	}
+	u.until = now().Add(backoff)
+}
+
+func ResetUnreachable() {
+	unreachables.Lock()
+	defer unreachables.Unlock()
+
+	unreachables.m = make(map[string]*unreachable)
+}
@@ -0,0 +1,87 @@
+package cert
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnreachableBackoff(t *testing.T) {
+	current := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	dials := 0
+	serverCert = func(tg target) ([]*x509.Certificate, string, error) {
+		dials++
+		return nil, "", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	}
+	now = func() time.Time { return current }
+	UnreachableBackoff = time.Minute
+	MaxUnreachableBackoff = 3 * time.Minute
+	defer func() {
+		stubCert()
+		now = time.Now
+		UnreachableBackoff = 0
+		MaxUnreachableBackoff = time.Hour
+		ResetUnreachable()
+	}()
+
+	var tests = []struct {
+		after     time.Duration
+		wantDials int
+		skipped   bool
+	}{
+		{0, 1, false},
+		{30 * time.Second, 1, true},
+		{time.Minute, 2, false},
+		{time.Minute, 2, true},
+		{2 * time.Minute, 3, false},
+		{3 * time.Minute, 4, false},
+		{2 * time.Minute, 4, true},
+		{time.Minute, 5, false},
+	}
+
+	for i, test := range tests {
+		current = current.Add(test.after)
+		c := NewCert("dead.example.com")
+
+		if dials != test.wantDials {
+			t.Errorf(`%d: unexpected dials %d, want %d`, i, dials, test.wantDials)
+		}
+		if skipped := strings.HasPrefix(c.Error, "Skipped unreachable host"); skipped != test.skipped {
+			t.Errorf(`%d: unexpected Cert.Error %q`, i, c.Error)
+		}
+	}
+}
+
+func TestUnreachableForgottenOnSuccess(t *testing.T) {
+	UnreachableBackoff = time.Minute
+	defer func() {
+		UnreachableBackoff = 0
+		ResetUnreachable()
+	}()
+
+	recordReachability("example.com:443", &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+	if _, ok := unreachableUntil("example.com:443"); !ok {
+		t.Fatal(`example.com:443 was not recorded as unreachable`)
+	}
+
+	recordReachability("example.com:443", nil)
+	if _, ok := unreachableUntil("example.com:443"); ok {
+		t.Error(`example.com:443 was not forgotten after success`)
+	}
+}
+
+func TestUnreachableIgnoresTLSErrors(t *testing.T) {
+	UnreachableBackoff = time.Minute
+	defer func() {
+		UnreachableBackoff = 0
+		ResetUnreachable()
+	}()
+
+	recordReachability("example.com:443", x509.UnknownAuthorityError{})
+	if _, ok := unreachableUntil("example.com:443"); ok {
+		t.Error(`certificate error was recorded as unreachable`)
+	}
+}
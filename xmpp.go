@@ -0,0 +1,87 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+)
+
+const xmppTLSNamespace = "urn:ietf:params:xml:ns:xmpp-tls"
+
+func xmppStartTLS(namespace string) func(net.Conn, string) error {
+	return func(conn net.Conn, domain string) error {
+		var to bytes.Buffer
+		if err := xml.EscapeText(&to, []byte(domain)); err != nil {
+			return err
+		}
+
+		// The 'to' attribute must name the XMPP domain, not the host that
+		// SRV resolution led us to, or servers hosting several domains
+		// pick the wrong certificate.
+		header := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='%s' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", to.String(), namespace)
+		if _, err := io.WriteString(conn, header); err != nil {
+			return err
+		}
+
+		dec := xml.NewDecoder(conn)
+		if err := xmppExpectStartTLS(dec); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(conn, "<starttls xmlns='"+xmppTLSNamespace+"'/>"); err != nil {
+			return err
+		}
+
+		return xmppExpectProceed(dec)
+	}
+}
+
+func xmppExpectStartTLS(dec *xml.Decoder) error {
+	inFeatures := false
+	offered := false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch el := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case el.Name.Local == "features":
+				inFeatures = true
+			case el.Name.Local == "error":
+				return fmt.Errorf("XMPP server returned a stream error.")
+			case inFeatures && el.Name.Local == "starttls" && el.Name.Space == xmppTLSNamespace:
+				offered = true
+			}
+		case xml.EndElement:
+			if inFeatures && el.Name.Local == "features" {
+				if !offered {
+					return fmt.Errorf("XMPP server does not offer STARTTLS.")
+				}
+				return nil
+			}
+		}
+	}
+}
+
+func xmppExpectProceed(dec *xml.Decoder) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		if el, ok := tok.(xml.StartElement); ok {
+			switch el.Name.Local {
+			case "proceed":
+				return nil
+			case "failure":
+				return fmt.Errorf("XMPP server refused STARTTLS.")
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cert
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+func fakeXMPPServer(conn net.Conn, features, reply string) <-chan string {
+	header := make(chan string, 1)
+	go func() {
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		h, _ := r.ReadString('>')
+		for !strings.Contains(h, "<stream:stream") {
+			next, err := r.ReadString('>')
+			if err != nil {
+				return
+			}
+			h = next
+		}
+		header <- h
+
+		conn.Write([]byte("<?xml version='1.0'?><stream:stream from='example.com' id='1' xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>"))
+		conn.Write([]byte("<stream:features>" + features + "</stream:features>"))
+		if _, err := r.ReadString('>'); err != nil {
+			return
+		}
+		conn.Write([]byte(reply))
+	}()
+	return header
+}
+
+func TestXMPPStartTLS(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	header := fakeXMPPServer(server, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls><mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>", "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+
+	if err := xmppStartTLS("jabber:client")(client, "example.com"); err != nil {
+		t.Fatalf(`unexpected err %s, want nil`, err.Error())
+	}
+
+	h := <-header
+	if !strings.Contains(h, "to='example.com'") {
+		t.Errorf(`unexpected stream header %q, want to='example.com'`, h)
+	}
+	if !strings.Contains(h, "xmlns='jabber:client'") {
+		t.Errorf(`unexpected stream header %q, want xmlns='jabber:client'`, h)
+	}
+}
+
+func TestXMPPStartTLSNotOffered(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	fakeXMPPServer(server, "<mechanisms xmlns='urn:ietf:params:xml:ns:xmpp-sasl'/>", "")
+
+	if err := xmppStartTLS("jabber:client")(client, "example.com"); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}
+
+func TestXMPPStartTLSFailure(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	fakeXMPPServer(server, "<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>", "<failure xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+
+	if err := xmppStartTLS("jabber:server")(client, "example.com"); err == nil {
+		t.Error(`unexpected nil, want error`)
+	}
+}